@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// changes lets goroutines wait for the executor counters to change without missing any change: waiters take the
+// current channel before checking the counters, and each notification closes it and replaces it with a new one
+type changes struct {
+	waiters atomic.Int64
+	lock    sync.Mutex
+	ch      chan struct{}
+}
+
+func newChanges() *changes {
+	return &changes{ch: make(chan struct{})}
+}
+
+// notify wakes up all goroutines waiting for a change. It is cheap when nobody is waiting, which is the common case
+func (c *changes) notify() {
+	if c.waiters.Load() == 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	close(c.ch)
+	c.ch = make(chan struct{})
+}
+
+// next returns a channel closed on the next notification
+func (c *changes) next() <-chan struct{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ch
+}
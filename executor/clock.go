@@ -0,0 +1,27 @@
+package executor
+
+import "time"
+
+// Clock is the source of time used to schedule jobs. See WithClock
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once the duration d elapses
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function call scheduled by Clock.AfterFunc
+type Timer interface {
+	// Stop prevents the call from happening, returning false if it already happened or the timer was already stopped
+	Stop() bool
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"context"
+	"iter"
 	"reflect"
 
 	"github.com/bcap/go-lib/result"
@@ -9,6 +10,8 @@ import (
 
 // Collect applies a function to all elements in the given slice or array, returning a slice with the results in the same order of the inputs
 //
+// Passing anything other than a slice or array panics at runtime. Prefer CollectSlice, which is checked at compile time
+//
 // The input to the function is the index in the slice. The usage is similar to the sort.Slice function. Example:
 //
 //	inputs := []int{100, 200, 300}
@@ -22,23 +25,104 @@ func Collect[T any](ctx context.Context, maxParallelism int, slice any, fn func(
 
 // CollectE is the same as Collect, but you can pass an existing Executor
 func CollectE[T any](ctx context.Context, e *Executor[T], slice any, fn func(int) (T, error)) result.Results[T] {
+	return CollectCtxE(ctx, e, slice, func(_ context.Context, i int) (T, error) { return fn(i) })
+}
+
+// CollectCtx is the same as Collect, but the function also receives the job context, which is cancelled when ctx
+// is done or the executor is shut down with ShutdownNow
+func CollectCtx[T any](ctx context.Context, maxParallelism int, slice any, fn func(context.Context, int) (T, error)) result.Results[T] {
+	return CollectCtxE(ctx, New[T](maxParallelism), slice, fn)
+}
+
+// CollectCtxE is the same as CollectCtx, but you can pass an existing Executor
+func CollectCtxE[T any](ctx context.Context, e *Executor[T], slice any, fn func(context.Context, int) (T, error)) result.Results[T] {
+	return CollectFutures(ctx, submitSlice(ctx, e, slice, fn))
+}
+
+func submitSlice[T any](ctx context.Context, e *Executor[T], slice any, fn func(context.Context, int) (T, error)) []*Future[T] {
 	if slice == nil {
-		return result.Results[T]{}
+		return []*Future[T]{}
 	}
 	sliceVal := reflect.ValueOf(slice)
 	sliceKind := sliceVal.Kind()
 	if sliceKind != reflect.Slice && sliceKind != reflect.Array {
 		panic("expected a slice or array")
 	}
-	futures := make([]*Future[T], sliceVal.Len())
+	return submitN(ctx, e, sliceVal.Len(), fn)
+}
+
+// submitN submits fn once for each index in [0, n)
+func submitN[T any](ctx context.Context, e *Executor[T], n int, fn func(context.Context, int) (T, error)) []*Future[T] {
+	futures := make([]*Future[T], n)
 	for i := range futures {
-		futures[i] = e.Submit(ctx, func() (T, error) {
-			return fn(i)
+		futures[i] = e.SubmitCtx(ctx, func(ctx context.Context) (T, error) {
+			return fn(ctx, i)
 		})
 	}
+	return futures
+}
+
+// CollectSlice applies a function to all elements in the given slice, returning a slice with the results in the same
+// order of the inputs. It is the type safe version of Collect: the function receives both the index and the element,
+// and there is no reflection involved. Example:
+//
+//	inputs := []int{100, 200, 300}
+//	results := CollectSlice(ctx, 0, inputs, func(i int, v int) (string, error) {
+//		return strconv.Itoa(v + 10), nil
+//	})
+//	reflect.DeepEqual(results.Values(), []string{"110", "210", "310"}) // true
+func CollectSlice[In, Out any](ctx context.Context, maxParallelism int, inputs []In, fn func(int, In) (Out, error)) result.Results[Out] {
+	return CollectSliceE(ctx, New[Out](maxParallelism), inputs, fn)
+}
+
+// CollectSliceE is the same as CollectSlice, but you can pass an existing Executor
+func CollectSliceE[In, Out any](ctx context.Context, e *Executor[Out], inputs []In, fn func(int, In) (Out, error)) result.Results[Out] {
+	return CollectSliceCtxE(ctx, e, inputs, func(_ context.Context, i int, input In) (Out, error) { return fn(i, input) })
+}
+
+// CollectSliceCtx is the same as CollectSlice, but the function also receives the job context, which is cancelled
+// when ctx is done or the executor is shut down with ShutdownNow
+func CollectSliceCtx[In, Out any](ctx context.Context, maxParallelism int, inputs []In, fn func(context.Context, int, In) (Out, error)) result.Results[Out] {
+	return CollectSliceCtxE(ctx, New[Out](maxParallelism), inputs, fn)
+}
+
+// CollectSliceCtxE is the same as CollectSliceCtx, but you can pass an existing Executor
+func CollectSliceCtxE[In, Out any](ctx context.Context, e *Executor[Out], inputs []In, fn func(context.Context, int, In) (Out, error)) result.Results[Out] {
+	futures := submitN(ctx, e, len(inputs), func(ctx context.Context, i int) (Out, error) { return fn(ctx, i, inputs[i]) })
 	return CollectFutures(ctx, futures)
 }
 
+// CollectEntries applies a function to all entries in the given map, returning a map of key -> results. Example:
+//
+//	prices := map[string]float64{"a": 1.5, "b": 2}
+//	results := CollectEntries(ctx, 0, prices, func(key string, price float64) (float64, error) {
+//		return price * 2, nil
+//	})
+//	reflect.DeepEqual(results.Values(), map[string]float64{"a": 3, "b": 4}) // true
+func CollectEntries[K comparable, V, Out any](ctx context.Context, maxParallelism int, entries map[K]V, fn func(K, V) (Out, error)) result.ResultsMap[K, Out] {
+	return CollectEntriesE(ctx, New[Out](maxParallelism), entries, fn)
+}
+
+// CollectEntriesE is the same as CollectEntries, but you can pass an existing Executor
+func CollectEntriesE[K comparable, V, Out any](ctx context.Context, e *Executor[Out], entries map[K]V, fn func(K, V) (Out, error)) result.ResultsMap[K, Out] {
+	return CollectEntriesCtxE(ctx, e, entries, func(_ context.Context, key K, value V) (Out, error) { return fn(key, value) })
+}
+
+// CollectEntriesCtx is the same as CollectEntries, but the function also receives the job context, which is
+// cancelled when ctx is done or the executor is shut down with ShutdownNow
+func CollectEntriesCtx[K comparable, V, Out any](ctx context.Context, maxParallelism int, entries map[K]V, fn func(context.Context, K, V) (Out, error)) result.ResultsMap[K, Out] {
+	return CollectEntriesCtxE(ctx, New[Out](maxParallelism), entries, fn)
+}
+
+// CollectEntriesCtxE is the same as CollectEntriesCtx, but you can pass an existing Executor
+func CollectEntriesCtxE[K comparable, V, Out any](ctx context.Context, e *Executor[Out], entries map[K]V, fn func(context.Context, K, V) (Out, error)) result.ResultsMap[K, Out] {
+	futures := make(map[K]*Future[Out], len(entries))
+	for key, value := range entries {
+		futures[key] = e.SubmitCtx(ctx, func(ctx context.Context) (Out, error) { return fn(ctx, key, value) })
+	}
+	return CollectFuturesMap(ctx, futures)
+}
+
 // CollectMap applies a function to all given keys, returning a map of key -> results
 //
 // The function is called with each key, and the result is stored in the map.
@@ -56,11 +140,22 @@ func CollectMap[K comparable, T any](ctx context.Context, maxParallelism int, en
 
 // CollectMapE is the same as CollectMap, but you can pass an existing Executor instance
 func CollectMapE[K comparable, T any](ctx context.Context, e *Executor[T], entries []K, fn func(K) (T, error)) result.ResultsMap[K, T] {
+	return CollectMapCtxE(ctx, e, entries, func(_ context.Context, key K) (T, error) { return fn(key) })
+}
+
+// CollectMapCtx is the same as CollectMap, but the function also receives the job context, which is cancelled when
+// ctx is done or the executor is shut down with ShutdownNow
+func CollectMapCtx[K comparable, T any](ctx context.Context, maxParallelism int, entries []K, fn func(context.Context, K) (T, error)) result.ResultsMap[K, T] {
+	return CollectMapCtxE(ctx, New[T](maxParallelism), entries, fn)
+}
+
+// CollectMapCtxE is the same as CollectMapCtx, but you can pass an existing Executor instance
+func CollectMapCtxE[K comparable, T any](ctx context.Context, e *Executor[T], entries []K, fn func(context.Context, K) (T, error)) result.ResultsMap[K, T] {
 	results := map[K]*result.Result[T]{}
 	for _, key := range entries {
 		results[key] = nil
 	}
-	CollectMapReplaceE(ctx, e, results, fn)
+	CollectMapReplaceCtxE(ctx, e, results, fn)
 	return results
 }
 
@@ -81,16 +176,78 @@ func CollectMapReplace[K comparable, T any](ctx context.Context, maxParallelism
 
 // CollectMapReplaceE is the same as CollectMapReplace, but you can pass an existing Executor instance
 func CollectMapReplaceE[K comparable, T any](ctx context.Context, e *Executor[T], m map[K]*result.Result[T], fn func(K) (T, error)) {
-	futures := map[K]*Future[T]{}
+	CollectMapReplaceCtxE(ctx, e, m, func(_ context.Context, key K) (T, error) { return fn(key) })
+}
+
+// CollectMapReplaceCtx is the same as CollectMapReplace, but the function also receives the job context, which is
+// cancelled when ctx is done or the executor is shut down with ShutdownNow
+func CollectMapReplaceCtx[K comparable, T any](ctx context.Context, maxParallelism int, m map[K]*result.Result[T], fn func(context.Context, K) (T, error)) {
+	CollectMapReplaceCtxE(ctx, New[T](maxParallelism), m, fn)
+}
+
+// CollectMapReplaceCtxE is the same as CollectMapReplaceCtx, but you can pass an existing Executor instance
+func CollectMapReplaceCtxE[K comparable, T any](ctx context.Context, e *Executor[T], m map[K]*result.Result[T], fn func(context.Context, K) (T, error)) {
+	keys := make([]K, 0, len(m))
 	for key := range m {
-		key := key
-		futures[key] = e.Submit(ctx, func() (T, error) { return fn(key) })
+		keys = append(keys, key)
 	}
-	for key, future := range futures {
+	for key, future := range submitMap(ctx, e, keys, fn) {
 		m[key] = future.Get(ctx)
 	}
 }
 
+func submitMap[K comparable, T any](ctx context.Context, e *Executor[T], keys []K, fn func(context.Context, K) (T, error)) map[K]*Future[T] {
+	futures := map[K]*Future[T]{}
+	for _, key := range keys {
+		if _, ok := futures[key]; ok {
+			continue
+		}
+		futures[key] = e.SubmitCtx(ctx, func(ctx context.Context) (T, error) { return fn(ctx, key) })
+	}
+	return futures
+}
+
+// CollectFailFast is the same as CollectCtx, but stops at the first error, like errgroup does.
+//
+// The first function to fail cancels the context shared by all jobs. Jobs that did not start yet are skipped and get
+// a context.Canceled error, and jobs that are running see their context cancelled. Calling Error() on the returned
+// results reports the error that caused the cancellation first
+func CollectFailFast[T any](ctx context.Context, maxParallelism int, slice any, fn func(context.Context, int) (T, error)) result.Results[T] {
+	return CollectFailFastE(ctx, New[T](maxParallelism), slice, fn)
+}
+
+// CollectFailFastE is the same as CollectFailFast, but you can pass an existing Executor
+func CollectFailFastE[T any](ctx context.Context, e *Executor[T], slice any, fn func(context.Context, int) (T, error)) result.Results[T] {
+	// jobs share a context that gets cancelled on the first error, but results are still fetched with the caller
+	// context so that finished jobs report their real outcome
+	jobsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return CollectFutures(ctx, submitSlice(jobsCtx, e, slice, failFast(cancel, fn)))
+}
+
+// CollectMapFailFast is the same as CollectMapCtx, but stops at the first error. See CollectFailFast for details
+func CollectMapFailFast[K comparable, T any](ctx context.Context, maxParallelism int, entries []K, fn func(context.Context, K) (T, error)) result.ResultsMap[K, T] {
+	return CollectMapFailFastE(ctx, New[T](maxParallelism), entries, fn)
+}
+
+// CollectMapFailFastE is the same as CollectMapFailFast, but you can pass an existing Executor instance
+func CollectMapFailFastE[K comparable, T any](ctx context.Context, e *Executor[T], entries []K, fn func(context.Context, K) (T, error)) result.ResultsMap[K, T] {
+	jobsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return CollectFuturesMap(ctx, submitMap(jobsCtx, e, entries, failFast(cancel, fn)))
+}
+
+// failFast wraps fn so that any error, including panics, cancels the shared context
+func failFast[I, T any](cancel context.CancelFunc, fn func(context.Context, I) (T, error)) func(context.Context, I) (T, error) {
+	return func(ctx context.Context, input I) (T, error) {
+		res, err := call(ctx, func(ctx context.Context) (T, error) { return fn(ctx, input) })
+		if err != nil {
+			cancel()
+		}
+		return res, err
+	}
+}
+
 // CollectFutures is a helper function to collect results from a slice of Futures, returning a slice of Results
 func CollectFutures[T any](ctx context.Context, futures []*Future[T]) result.Results[T] {
 	results := make([]*result.Result[T], len(futures))
@@ -109,3 +266,46 @@ func CollectFuturesMap[K comparable, T any](ctx context.Context, futures map[K]*
 	}
 	return results
 }
+
+// Completed returns an iterator over the results of the given futures in the order they complete, instead of the
+// order they were given. Each result is yielded along with the index of its future. Example:
+//
+//	for i, r := range Completed(ctx, futures) {
+//		fmt.Printf("future %d completed with %v\n", i, r.Value)
+//	}
+//
+// If ctx is done before all futures complete, the remaining futures are yielded in index order, with their results
+// if they are done by then or with the context error otherwise
+func Completed[T any](ctx context.Context, futures []*Future[T]) iter.Seq2[int, *result.Result[T]] {
+	return func(yield func(int, *result.Result[T]) bool) {
+		// buffered so callbacks never block, even if the iteration stops early
+		completed := make(chan int, len(futures))
+		for i, f := range futures {
+			f.OnComplete(func(*result.Result[T]) { completed <- i })
+		}
+		yielded := make([]bool, len(futures))
+		for range futures {
+			select {
+			case i := <-completed:
+				yielded[i] = true
+				if !yield(i, futures[i].Get(ctx)) {
+					return
+				}
+			case <-ctx.Done():
+				for i, f := range futures {
+					if yielded[i] {
+						continue
+					}
+					r, ok := f.GetNoBlock()
+					if !ok {
+						r = &result.Result[T]{Err: ctx.Err()}
+					}
+					if !yield(i, r) {
+						return
+					}
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/bcap/go-lib/collection"
+	"github.com/bcap/go-lib/result"
+)
+
+// ErrNoFutures is the error set on futures returned by AnyOf and FirstSuccess when called without any futures
+var ErrNoFutures = errors.New("no futures given")
+
+// Then submits fn to the executor e once f completes successfully, passing it the value of f.
+// If f fails, fn is not run and the returned future holds the same error
+func Then[T any, U any](ctx context.Context, f *Future[T], e *Executor[U], fn func(context.Context, T) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	f.OnComplete(func(r *result.Result[T]) {
+		if r.Err != nil {
+			next.complete(&result.Result[U]{Err: r.Err})
+			return
+		}
+		// submitting may block when the executor queue is full, which must not hold the goroutine completing f
+		go func() {
+			e.SubmitCtx(ctx, func(ctx context.Context) (U, error) {
+				return fn(ctx, r.Value)
+			}).OnComplete(func(r *result.Result[U]) {
+				next.complete(r)
+			})
+		}()
+	})
+	return next
+}
+
+// Map transforms the value of f with fn once f completes successfully. If f fails, fn is not called and the
+// returned future holds the same error.
+//
+// fn runs in the goroutine completing f, so it should be cheap. Use Then for heavier work
+func Map[T any, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	f.OnComplete(func(r *result.Result[T]) {
+		if r.Err != nil {
+			next.complete(&result.Result[U]{Err: r.Err})
+			return
+		}
+		value, err := call(context.Background(), func(context.Context) (U, error) { return fn(r.Value) })
+		next.complete(&result.Result[U]{Value: value, Err: err})
+	})
+	return next
+}
+
+// AllOf returns a future that completes with the values of all given futures, in the same order, once they all
+// complete successfully. It fails as soon as any of the futures fails, with the same error
+func AllOf[T any](futures Futures[T]) *Future[[]T] {
+	values := make([]T, len(futures))
+	all, done := join(&values, len(futures))
+	for i, f := range futures {
+		watch(f, done, func(v T) { values[i] = v })
+	}
+	return all
+}
+
+// AnyOf returns a future that completes with the result of the first given future to complete, successfully or not
+func AnyOf[T any](futures Futures[T]) *Future[T] {
+	first := newFuture[T]()
+	if len(futures) == 0 {
+		first.complete(&result.Result[T]{Err: ErrNoFutures})
+		return first
+	}
+	for _, f := range futures {
+		f.OnComplete(func(r *result.Result[T]) {
+			first.complete(r)
+		})
+	}
+	return first
+}
+
+// FirstSuccess returns a future that completes with the value of the first given future to complete successfully.
+// If all futures fail, it fails with a result.ResultsError holding all errors, in the same order as the futures
+func FirstSuccess[T any](futures Futures[T]) *Future[T] {
+	first := newFuture[T]()
+	if len(futures) == 0 {
+		first.complete(&result.Result[T]{Err: ErrNoFutures})
+		return first
+	}
+	errs := make([]error, len(futures))
+	var remaining atomic.Int64
+	remaining.Store(int64(len(futures)))
+	for i, f := range futures {
+		f.OnComplete(func(r *result.Result[T]) {
+			if r.Err == nil {
+				first.complete(r)
+				return
+			}
+			errs[i] = r.Err
+			if remaining.Add(-1) == 0 {
+				first.complete(&result.Result[T]{Err: &result.ResultsError{Errors: errs}})
+			}
+		})
+	}
+	return first
+}
+
+// Combine2 returns a future that completes with the values of both futures once they complete successfully.
+// It fails as soon as any of them fails, with the same error
+func Combine2[T1 any, T2 any](f1 *Future[T1], f2 *Future[T2]) *Future[collection.Tuple2[T1, T2]] {
+	var tuple collection.Tuple2[T1, T2]
+	combined, done := join(&tuple, 2)
+	watch(f1, done, func(v T1) { tuple.V1 = v })
+	watch(f2, done, func(v T2) { tuple.V2 = v })
+	return combined
+}
+
+// Combine3 is the same as Combine2, but for 3 futures
+func Combine3[T1 any, T2 any, T3 any](f1 *Future[T1], f2 *Future[T2], f3 *Future[T3]) *Future[collection.Tuple3[T1, T2, T3]] {
+	var tuple collection.Tuple3[T1, T2, T3]
+	combined, done := join(&tuple, 3)
+	watch(f1, done, func(v T1) { tuple.V1 = v })
+	watch(f2, done, func(v T2) { tuple.V2 = v })
+	watch(f3, done, func(v T3) { tuple.V3 = v })
+	return combined
+}
+
+// join returns a future that completes with the contents of value once the returned done function is called count
+// times with a nil error, or with the first non nil error passed to done
+func join[V any](value *V, count int) (*Future[V], func(error)) {
+	future := newFuture[V]()
+	if count == 0 {
+		future.complete(&result.Result[V]{Value: *value})
+	}
+	var remaining atomic.Int64
+	remaining.Store(int64(count))
+	return future, func(err error) {
+		if err != nil {
+			future.complete(&result.Result[V]{Err: err})
+			return
+		}
+		if remaining.Add(-1) == 0 {
+			future.complete(&result.Result[V]{Value: *value})
+		}
+	}
+}
+
+// watch calls set with the value of f if it succeeds, and then done with its error
+func watch[T any](f *Future[T], done func(error), set func(T)) {
+	f.OnComplete(func(r *result.Result[T]) {
+		if r.Err == nil {
+			set(r.Value)
+		}
+		done(r.Err)
+	})
+}
@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bcap/go-lib/collection"
+	"github.com/bcap/go-lib/result"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThenAndMap(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	ints := New[int](2)
+	strs := New[string](2)
+
+	f := ints.Submit(ctx, func() (int, error) { return 21, nil })
+	doubled := Then(ctx, f, ints, func(_ context.Context, v int) (int, error) { return v * 2, nil })
+	str := Then(ctx, doubled, strs, func(_ context.Context, v int) (string, error) { return strconv.Itoa(v), nil })
+	require.Equal(t, "42", str.Get(ctx).Must())
+	require.Equal(t, "42!", Map(str, func(v string) (string, error) { return v + "!", nil }).Get(ctx).Must())
+
+	// errors propagate without running the next stages
+	failed := ints.Submit(ctx, func() (int, error) { return 0, errBoom })
+	called := false
+	next := Then(ctx, failed, strs, func(_ context.Context, v int) (string, error) {
+		called = true
+		return "", nil
+	})
+	require.ErrorIs(t, Map(next, func(v string) (int, error) { return len(v), nil }).Get(ctx).Err, errBoom)
+	require.False(t, called)
+
+	// panics in Map are captured
+	var panicErr *PanicError
+	require.ErrorAs(t, Map(f, func(v int) (int, error) { panic("boom") }).Get(ctx).Err, &panicErr)
+}
+
+func TestAllOfAnyOfFirstSuccess(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	e := New[int](-1)
+
+	// jobs delayed by an hour never complete during the test
+	never := make(chan struct{})
+	defer close(never)
+	delayed := func(delay time.Duration, value int, err error) func() (int, error) {
+		return func() (int, error) {
+			select {
+			case <-time.After(delay):
+			case <-never:
+			}
+			return value, err
+		}
+	}
+
+	futures := Futures[int]{}
+	futures.Submit(ctx, e, delayed(20*time.Millisecond, 1, nil))
+	futures.Submit(ctx, e, delayed(0, 2, nil))
+	futures.Submit(ctx, e, delayed(10*time.Millisecond, 3, nil))
+	require.Equal(t, []int{1, 2, 3}, AllOf(futures).Get(ctx).Must())
+	require.Equal(t, []int{}, AllOf(Futures[int]{}).Get(ctx).Must())
+
+	futures = Futures[int]{}
+	futures.Submit(ctx, e, delayed(time.Hour, 1, nil))
+	futures.Submit(ctx, e, delayed(0, 0, errBoom))
+	require.ErrorIs(t, AllOf(futures).Get(ctx).Err, errBoom)
+
+	futures = Futures[int]{}
+	futures.Submit(ctx, e, delayed(time.Hour, 1, nil))
+	futures.Submit(ctx, e, delayed(0, 2, errBoom))
+	futures.Submit(ctx, e, delayed(10*time.Millisecond, 3, nil))
+	require.ErrorIs(t, AnyOf(futures).Get(ctx).Err, errBoom)
+	require.Equal(t, 3, FirstSuccess(futures).Get(ctx).Must())
+
+	futures = Futures[int]{}
+	futures.Submit(ctx, e, delayed(10*time.Millisecond, 0, errBoom))
+	futures.Submit(ctx, e, delayed(0, 0, context.Canceled))
+	var resultsErr *result.ResultsError
+	require.ErrorAs(t, FirstSuccess(futures).Get(ctx).Err, &resultsErr)
+	require.Equal(t, []error{errBoom, context.Canceled}, resultsErr.Errors)
+
+	require.ErrorIs(t, AnyOf(Futures[int]{}).Get(ctx).Err, ErrNoFutures)
+	require.ErrorIs(t, FirstSuccess(Futures[int]{}).Get(ctx).Err, ErrNoFutures)
+}
+
+func TestCombine(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	ints := New[int](0)
+	strs := New[string](0)
+	bools := New[bool](0)
+
+	i := ints.Submit(ctx, func() (int, error) { return 1, nil })
+	s := strs.Submit(ctx, func() (string, error) { return "a", nil })
+	b := bools.Submit(ctx, func() (bool, error) { return true, nil })
+
+	require.Equal(t, collection.Tuple2[int, string]{V1: 1, V2: "a"}, Combine2(i, s).Get(ctx).Must())
+	require.Equal(t, collection.Tuple3[int, string, bool]{V1: 1, V2: "a", V3: true}, Combine3(i, s, b).Get(ctx).Must())
+
+	failed := bools.Submit(ctx, func() (bool, error) { return false, errBoom })
+	require.ErrorIs(t, Combine3(i, s, failed).Get(ctx).Err, errBoom)
+}
+
+func TestCompleted(t *testing.T) {
+	ctx := context.Background()
+	e := New[int](-1)
+
+	release := []chan struct{}{make(chan struct{}), make(chan struct{}), make(chan struct{})}
+	futures := Futures[int]{}
+	for i := range release {
+		futures.Submit(ctx, e, func() (int, error) {
+			<-release[i]
+			return i, nil
+		})
+	}
+
+	var callbackResults []int
+	futures[1].OnComplete(func(r *result.Result[int]) { callbackResults = append(callbackResults, r.Value) })
+
+	// release the jobs in reverse order, one at a time as the iteration progresses
+	close(release[2])
+	order := []int{}
+	for i, r := range Completed(ctx, futures) {
+		require.Equal(t, i, r.Must())
+		order = append(order, i)
+		if i > 0 {
+			close(release[i-1])
+		}
+	}
+	require.Equal(t, []int{2, 1, 0}, order)
+	require.Equal(t, []int{1}, callbackResults)
+
+	// callbacks on completed futures run right away
+	futures[0].OnComplete(func(r *result.Result[int]) { callbackResults = append(callbackResults, r.Value) })
+	require.Equal(t, []int{1, 0}, callbackResults)
+
+	// futures still pending when the context is done are yielded with the context error
+	never := make(chan struct{})
+	defer close(never)
+	futures = Futures[int]{}
+	futures.Submit(ctx, e, func() (int, error) { <-never; return 0, nil })
+	futures.Submit(ctx, e, func() (int, error) { return 1, nil })
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	results := map[int]error{}
+	for i, r := range Completed(timeoutCtx, futures) {
+		results[i] = r.Err
+	}
+	require.Equal(t, map[int]error{0: context.DeadlineExceeded, 1: nil}, results)
+
+	// futures already done when the context is done keep their results
+	require.Equal(t, 1, futures[1].Get(ctx).Must())
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	results = map[int]error{}
+	for i, r := range Completed(cancelledCtx, futures) {
+		results[i] = r.Err
+	}
+	require.Equal(t, map[int]error{0: context.Canceled, 1: nil}, results)
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bcap/go-lib/result"
+)
+
+// dedupe tracks the jobs submitted with WithDedupeKey, so that submissions with the same key can share their futures
+type dedupe[T any] struct {
+	lock sync.Mutex
+	ttl  time.Duration
+	jobs map[any]*job[T]
+}
+
+func newDedupe[T any]() *dedupe[T] {
+	return &dedupe[T]{jobs: map[any]*job[T]{}}
+}
+
+// register associates j with key, unless there is already a job for it, in which case that one is returned along
+// with false
+func (d *dedupe[T]) register(key any, j *job[T], clock Clock) (*job[T], bool) {
+	d.lock.Lock()
+	if existing, ok := d.jobs[key]; ok {
+		d.lock.Unlock()
+		return existing, false
+	}
+	d.jobs[key] = j
+	d.lock.Unlock()
+
+	j.future.OnComplete(func(r *result.Result[T]) {
+		if r.Err != nil || d.ttl <= 0 {
+			d.forget(key, j)
+			return
+		}
+		clock.AfterFunc(d.ttl, func() { d.forget(key, j) })
+	})
+	return j, true
+}
+
+func (d *dedupe[T]) forget(key any, j *job[T]) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.jobs[key] == j {
+		delete(d.jobs, key)
+	}
+}
@@ -2,95 +2,715 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"runtime"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bcap/go-lib/result"
-	"golang.org/x/sync/semaphore"
 )
 
+// ErrShutdown is the error set on futures of jobs submitted after the executor was shut down, and of jobs that were
+// dropped by ShutdownNow before they got to run
+var ErrShutdown = errors.New("executor is shut down")
+
+// PanicError is the error set on the result of a job whose function panicked
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the job goroutine at the moment of the panic
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error, so errors.Is and errors.As can inspect it
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// TimeoutError is the error set on the result of jobs that did not finish within their timeout.
+// It wraps context.DeadlineExceeded
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("job timed out after %v", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// ErrInvalidWeight is the error set on futures of jobs submitted with a weight lower than 1 or higher than the
+// executor maxParallelism
+var ErrInvalidWeight = errors.New("invalid job weight")
+
 type Executor[T any] struct {
-	maxParallelism int
-	semaphore      *semaphore.Weighted
+	maxParallelism atomic.Int64
+	limiter        *limiter
 	submitted      atomic.Int64
 	launched       atomic.Int64
 	inFlight       atomic.Int64
 	done           atomic.Int64
 	pending        atomic.Int64
+	attempts       atomic.Int64
+	retries        atomic.Int64
+	timedOut       atomic.Int64
+	cancelled      atomic.Int64
+	deduplicated   atomic.Int64
+	throttled      atomic.Int64
+	throttledTime  atomic.Int64
+	failed         atomic.Int64
+	peakInFlight   atomic.Int64
+
+	// statsLock makes Stats see a consistent snapshot: updates touching several counters at once hold it for
+	// reading, while Stats holds it for writing
+	statsLock  sync.RWMutex
+	created    time.Time
+	queueWaits samples
+	runTimes   samples
+
+	queue   *queue[T] // only set when running with WithWorkers
+	retry   RetryPolicy
+	timeout time.Duration
+	rate    *rateLimiter // only set when running with WithRateLimit
+	dedupe  *dedupe[T]
+	clock   Clock
+
+	interceptors []Interceptor[T] // wrap every job, the first one being the outermost
 
-	waitInactiveLock *sync.Mutex
-	waitInactiveCond *sync.Cond
+	// lifecycle
+	lock       sync.Mutex
+	seq        uint64
+	unstarted  map[*job[T]]struct{}
+	shutdown   bool
+	terminated chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	changes *changes // notified whenever the counters change
 
 	testSyncCheckpoint chan struct{} // used only for testing manipulation
 }
 
-func New[T any](maxParallelism int) *Executor[T] {
+type Option[T any] func(*Executor[T])
+
+// WithWorkers makes the executor run jobs on a fixed set of worker goroutines (at most maxParallelism of them)
+// consuming from a queue, instead of launching one goroutine per submitted job.
+//
+// A queueSize <= 0 means the queue is unbounded. Otherwise Submit blocks while the queue is full, until there is
+// room for the job or the submission context is done.
+//
+// Workers are started on demand and stop when there are no more jobs queued, so an idle executor holds no goroutines
+func WithWorkers[T any](queueSize int) Option[T] {
+	return func(e *Executor[T]) {
+		if e.MaxParallelism() < 0 {
+			panic("workers require a positive maxParallelism")
+		}
+		e.queue = newQueue[T](queueSize)
+	}
+}
+
+// WithRetry sets the default retry policy for jobs submitted to the executor.
+// It can be overridden per submission with WithJobRetry
+func WithRetry[T any](policy RetryPolicy) Option[T] {
+	return func(e *Executor[T]) {
+		e.retry = policy
+	}
+}
+
+// WithTimeout sets the default timeout for jobs submitted to the executor.
+// It can be overridden per submission with WithJobTimeout.
+//
+// The timeout counts the time the job spends running, including retries, but not the time it waits for its turn to
+// run. When it expires the job context is cancelled and its future completes with a TimeoutError
+func WithTimeout[T any](timeout time.Duration) Option[T] {
+	return func(e *Executor[T]) {
+		e.timeout = timeout
+	}
+}
+
+// WithRateLimit limits how many jobs per second the executor starts, on top of the maxParallelism limit. Up to burst
+// jobs can start at once after a period of inactivity.
+//
+// Jobs wait for the rate limit after getting their turn to run, and while waiting they keep their parallelism slot.
+// The waiting honors the submission context, and is reported by Throttled and ThrottledTime.
+// A rate <= 0 means no rate limit
+func WithRateLimit[T any](rate float64, burst int) Option[T] {
+	return func(e *Executor[T]) {
+		if rate <= 0 {
+			e.rate = nil
+			return
+		}
+		e.rate = newRateLimiter(rate, burst)
+	}
+}
+
+// WithDedupeTTL makes the successful results of jobs submitted with WithDedupeKey be shared with later submissions
+// using the same key for the given time after they complete. Failed results are never kept.
+// A ttl <= 0, the default, means results are only shared while the job is pending
+func WithDedupeTTL[T any](ttl time.Duration) Option[T] {
+	return func(e *Executor[T]) {
+		e.dedupe.ttl = ttl
+	}
+}
+
+// WithInterceptors adds interceptors wrapping every job run by the executor, whatever API was used to submit it.
+// Interceptors compose in order: the first one given is the outermost, and sees the job result last.
+// Calling WithInterceptors more than once appends to the interceptors already set. See Interceptor
+func WithInterceptors[T any](interceptors ...Interceptor[T]) Option[T] {
+	return func(e *Executor[T]) {
+		e.interceptors = append(e.interceptors, interceptors...)
+	}
+}
+
+// WithClock sets the clock used to schedule jobs submitted with SubmitAfter, SubmitAt and SubmitEvery, and to expire
+// results kept by WithDedupeTTL. Meant for testing, as it defaults to the system clock
+func WithClock[T any](clock Clock) Option[T] {
+	return func(e *Executor[T]) {
+		e.clock = clock
+	}
+}
+
+// SubmitOption customizes a single job submission, overriding the executor defaults
+type SubmitOption func(*submitOptions)
+
+type submitOptions struct {
+	retry     *RetryPolicy
+	timeout   *time.Duration
+	priority  int
+	weight    int
+	dedupeKey any
+	delay     time.Duration // set by SubmitAfter and SubmitAt
+	future    any           // *Future[T] used instead of a new one, set by KeyedExecutor
+	onFinish  func()        // set by KeyedExecutor and SubmitEvery. See withOnFinish
+}
+
+// WithJobRetry sets the retry policy of the submitted job
+func WithJobRetry(policy RetryPolicy) SubmitOption {
+	return func(o *submitOptions) {
+		o.retry = &policy
+	}
+}
+
+// WithJobTimeout sets the timeout of the submitted job. A timeout <= 0 disables the executor default timeout
+func WithJobTimeout(timeout time.Duration) SubmitOption {
+	return func(o *submitOptions) {
+		o.timeout = &timeout
+	}
+}
+
+// WithPriority sets the priority of the submitted job. Jobs waiting for their turn to run are dispatched highest
+// priority first, and in submission order among jobs of the same priority. The default priority is 0
+func WithPriority(priority int) SubmitOption {
+	return func(o *submitOptions) {
+		o.priority = priority
+	}
+}
+
+// WithWeight sets how many parallelism slots the submitted job takes while running, so that heavier jobs can share
+// an executor with lighter ones without going over its capacity. The default weight is 1.
+//
+// The weight must not be higher than the executor maxParallelism, otherwise the job is rejected with
+// ErrInvalidWeight
+func WithWeight(weight int) SubmitOption {
+	return func(o *submitOptions) {
+		o.weight = weight
+	}
+}
+
+// WithDedupeKey deduplicates the submitted job: while a job submitted with the same key is pending, the submission
+// does not create a new job but returns the future of the pending one instead. See WithDedupeTTL to also share
+// completed results.
+//
+// The key must be comparable. Deduplicated submissions share everything with the original one, including its
+// context, options and cancellation: cancelling the shared future cancels it for all its holders
+func WithDedupeKey(key any) SubmitOption {
+	return func(o *submitOptions) {
+		o.dedupeKey = key
+	}
+}
+
+// withOnFinish sets a function called once the submission is done with: when its job finishes, even if its future
+// completed earlier, or right away when the job is rejected. Deduplicated submissions are done with once the job they
+// share finishes
+func withOnFinish(onFinish func()) SubmitOption {
+	return func(o *submitOptions) {
+		o.onFinish = onFinish
+	}
+}
+
+func New[T any](maxParallelism int, opts ...Option[T]) *Executor[T] {
 	if maxParallelism == 0 {
 		maxParallelism = runtime.NumCPU()
 	}
-	var waitInactiveLock sync.Mutex
-	return &Executor[T]{
-		maxParallelism:   maxParallelism,
-		semaphore:        semaphore.NewWeighted(int64(maxParallelism)),
-		waitInactiveCond: sync.NewCond(&waitInactiveLock),
-		waitInactiveLock: &waitInactiveLock,
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Executor[T]{
+		limiter:    newLimiter(int64(maxParallelism)),
+		unstarted:  map[*job[T]]struct{}{},
+		dedupe:     newDedupe[T](),
+		clock:      realClock{},
+		terminated: make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+		changes:    newChanges(),
+	}
+	e.maxParallelism.Store(int64(maxParallelism))
+	for _, opt := range opts {
+		opt(e)
 	}
+	e.created = e.clock.Now()
+	return e
 }
 
 // Submits sends a new job to the executor.
 // The job will be executed as soon as possible, respecting the maxParallelism setting
-func (e *Executor[T]) Submit(ctx context.Context, fn func() (T, error)) *Future[T] {
+//
+// If the executor is shut down the job is not executed and the returned future holds ErrShutdown
+func (e *Executor[T]) Submit(ctx context.Context, fn func() (T, error), opts ...SubmitOption) *Future[T] {
+	return e.SubmitCtx(ctx, func(context.Context) (T, error) { return fn() }, opts...)
+}
+
+// SubmitCtx is the same as Submit, but the function receives the job context.
+// The job context is derived from ctx and is also cancelled when the executor is shut down with ShutdownNow, so
+// long running functions can stop early
+func (e *Executor[T]) SubmitCtx(ctx context.Context, fn func(context.Context) (T, error), opts ...SubmitOption) *Future[T] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	options := submitOptions{weight: 1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	future, _ := options.future.(*Future[T])
+	if future == nil {
+		future = newFuture[T]()
+		future.state.Store(int32(AwaitingExecution))
+	}
+
+	// the job context is cancelled either by the submission context or by ShutdownNow
+	jobCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(e.ctx, cancel)
+	j := &job[T]{
+		ctx:      jobCtx,
+		cancel:   func() { stop(); cancel() },
+		fn:       fn,
+		future:   future,
+		retry:    e.retry,
+		timeout:  e.timeout,
+		priority: options.priority,
+		weight:   int64(options.weight),
+		index:    -1,
+	}
+	if options.dedupeKey != nil {
+		if existing, registered := e.dedupe.register(options.dedupeKey, j, e.clock); !registered {
+			j.cancel()
+			e.deduplicated.Add(1)
+			e.changes.notify()
+			if options.onFinish != nil {
+				// there is no job of its own to finish, the submission is done with once the shared one finishes
+				existing.addOnFinish(options.onFinish)
+			}
+			return existing.future
+		}
+	}
+	if options.onFinish != nil {
+		j.addOnFinish(options.onFinish)
+	}
+	if options.retry != nil {
+		j.retry = *options.retry
+	}
+	if options.timeout != nil {
+		j.timeout = *options.timeout
+	}
+
+	if maxParallelism := e.MaxParallelism(); options.weight < 1 || maxParallelism > 0 && options.weight > maxParallelism {
+		j.cancel()
+		err := fmt.Errorf("%w: weight %d with maxParallelism %d", ErrInvalidWeight, options.weight, maxParallelism)
+		future.complete(&result.Result[T]{Err: err})
+		j.finished()
+		return future
+	}
+
+	e.lock.Lock()
+	if e.shutdown {
+		e.lock.Unlock()
+		j.cancel()
+		future.complete(&result.Result[T]{Err: ErrShutdown})
+		j.finished()
+		return future
+	}
+	e.statsLock.RLock()
 	e.submitted.Add(1)
 	e.pending.Add(1)
-	future := newFuture[T]()
-	future.state.Store(int32(AwaitingExecution))
-	go func() {
-		e.launched.Add(1)
-		defer e.done.Add(1)
-		defer func() {
-			pending := e.pending.Add(-1)
-			if pending == 0 {
-				e.waitInactiveCond.Broadcast()
-			}
-		}()
+	e.statsLock.RUnlock()
+	e.seq++
+	j.seq = e.seq
+	e.unstarted[j] = struct{}{}
+	e.lock.Unlock()
+	e.changes.notify()
+	future.setOnCancel(func() { e.cancelJob(j) })
 
-		// inspection point used in testing
-		if e.testSyncCheckpoint != nil {
-			<-e.testSyncCheckpoint
+	if options.delay > 0 {
+		e.schedule(j, options.delay)
+		return future
+	}
+	e.dispatch(j)
+	return future
+}
+
+// dispatch hands a job over to be run, either in a new goroutine or through the workers queue
+func (e *Executor[T]) dispatch(j *job[T]) {
+	j.dispatched = e.clock.Now()
+	if e.queue == nil {
+		go e.run(j)
+		return
+	}
+
+	startWorker, err := e.queue.push(j.ctx, j, e.MaxParallelism())
+	if err != nil {
+		// the queue was full and the job could not be enqueued before the context was done
+		if e.claim(j, jobDropped) {
+			e.finish(j, &result.Result[T]{Err: err})
 		}
+		return
+	}
+	if startWorker {
+		go e.work()
+	}
+}
 
-		// wait until its our turn to run the function
-		if ctx == nil {
-			ctx = context.Background()
+// SubmitWithPriority is the same as SubmitCtx, but the job is dispatched according to the given priority.
+// See WithPriority
+func (e *Executor[T]) SubmitWithPriority(ctx context.Context, priority int, fn func(context.Context) (T, error), opts ...SubmitOption) *Future[T] {
+	return e.SubmitCtx(ctx, fn, append([]SubmitOption{WithPriority(priority)}, opts...)...)
+}
+
+// work is the loop of a worker goroutine. It keeps running queued jobs until the queue is empty
+func (e *Executor[T]) work() {
+	for j := e.queue.pop(e.MaxParallelism()); j != nil; j = e.queue.pop(e.MaxParallelism()) {
+		e.run(j)
+	}
+}
+
+func (e *Executor[T]) run(j *job[T]) {
+	// jobs dropped before getting here, like cancelled ones, are already finished and never launch
+	if jobState(j.state.Load()) != jobQueued {
+		return
+	}
+	e.launched.Add(1)
+	e.changes.notify()
+
+	// inspection point used in testing
+	if e.testSyncCheckpoint != nil {
+		<-e.testSyncCheckpoint
+	}
+
+	// wait until its our turn to run the function. Jobs with higher priority go first
+	if err := e.acquire(j); err != nil {
+		if e.claim(j, jobDropped) {
+			e.finish(j, &result.Result[T]{Err: err})
 		}
-		if e.maxParallelism > 0 {
-			if err := e.semaphore.Acquire(ctx, 1); err != nil {
-				future.resultC <- &result.Result[T]{Err: err}
-				return
-			}
+		return
+	}
+
+	// the job may have been dropped by ShutdownNow while waiting for its turn
+	if !e.claim(j, jobStarted) {
+		e.release(j)
+		return
+	}
+
+	// Critical Section Start | run the function
+	start := e.clock.Now()
+	e.queueWaits.add(start.Sub(j.dispatched))
+	e.statsLock.RLock()
+	inFlight := e.inFlight.Add(1)
+	for peak := e.peakInFlight.Load(); inFlight > peak && !e.peakInFlight.CompareAndSwap(peak, inFlight); {
+		peak = e.peakInFlight.Load()
+	}
+	e.statsLock.RUnlock()
+	e.changes.notify()
+	// the future may have been cancelled in the meantime, in which case it must stay Cancelled
+	j.future.state.CompareAndSwap(int32(AwaitingExecution), int32(Executing))
+	res, err := e.executeWithTimeout(j)
+
+	// Critical Section Stop | allow the next function to run
+	e.runTimes.add(e.clock.Now().Sub(start))
+	e.inFlight.Add(-1)
+	e.changes.notify()
+	e.release(j)
+
+	e.finish(j, &result.Result[T]{Value: res, Err: err})
+}
+
+// acquire waits until it is the job turn to run, respecting the maxParallelism setting and the rate limit.
+// Jobs whose context is done before their turn comes are not run and get the context error instead
+func (e *Executor[T]) acquire(j *job[T]) error {
+	if err := j.ctx.Err(); err != nil {
+		return err
+	}
+	acquired, err := e.limiter.acquire(j.ctx, j.weight, j.priority, j.seq)
+	if err != nil {
+		return err
+	}
+	j.acquired = acquired
+	if e.rate != nil {
+		waited, err := e.rate.wait(j.ctx)
+		if waited > 0 {
+			e.throttled.Add(1)
+			e.throttledTime.Add(int64(waited))
+			e.changes.notify()
+		}
+		if err != nil {
+			e.release(j)
+			return err
+		}
+	}
+	if err := j.ctx.Err(); err != nil {
+		e.release(j)
+		return err
+	}
+	return nil
+}
+
+func (e *Executor[T]) release(j *job[T]) {
+	e.limiter.release(j.acquired)
+}
+
+// executeWithTimeout runs the job enforcing its timeout, if any.
+//
+// When the timeout expires the job context is cancelled and the future is completed right away with a TimeoutError,
+// even if the job function does not return yet. The function keeps its parallelism slot until it actually returns
+func (e *Executor[T]) executeWithTimeout(j *job[T]) (T, error) {
+	if j.timeout <= 0 {
+		return e.intercept(j.ctx, j)
+	}
+
+	timeoutErr := &TimeoutError{Timeout: j.timeout}
+	ctx, cancel := context.WithTimeoutCause(j.ctx, j.timeout, timeoutErr)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		if context.Cause(ctx) == timeoutErr {
+			e.timedOut.Add(1)
+			e.changes.notify()
+			j.future.complete(&result.Result[T]{Err: timeoutErr})
 		}
+	})
 
-		// Critical Section Start | run the function
-		e.inFlight.Add(1)
-		future.setState(Executing)
-		res, err := fn()
+	res, err := e.intercept(ctx, j)
+	stopped := stop()
+	if context.Cause(ctx) == timeoutErr {
+		// the timeout expired, possibly just before the function returned and the timeout callback had a chance to
+		// run. Report the timeout in any case, counting it only if the callback did not run
+		if stopped {
+			e.timedOut.Add(1)
+			e.changes.notify()
+		}
+		var zeroVal T
+		return zeroVal, timeoutErr
+	}
+	return res, err
+}
 
-		// Critical Section Stop | allow the next function to run
-		e.inFlight.Add(-1)
-		if e.maxParallelism > 0 {
-			e.semaphore.Release(1)
+// execute runs the job function, retrying it according to the job retry policy
+func (e *Executor[T]) execute(ctx context.Context, j *job[T]) (T, error) {
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		j.future.attempts.Add(1)
+		e.attempts.Add(1)
+		e.changes.notify()
+		res, err := call(ctx, j.fn)
+		if err == nil || !j.retry.shouldRetry(ctx, attempt, err) {
+			return res, err
+		}
+		if j.retry.Backoff != nil {
+			delay = j.retry.Backoff(attempt, delay)
 		}
+		if sleep(ctx, delay) != nil {
+			return res, err
+		}
+		e.retries.Add(1)
+		e.changes.notify()
+	}
+}
 
-		// send results
-		future.resultC <- &result.Result[T]{Value: res, Err: err}
-		future.setState(ResultReady)
+// call runs the job function, turning a panic into a PanicError
+func call[T any](ctx context.Context, fn func(context.Context) (T, error)) (res T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
 	}()
-	return future
+	return fn(ctx)
+}
+
+// claim transitions a queued job to the given state, taking ownership of completing it.
+// Returns false if the job was already claimed
+func (e *Executor[T]) claim(j *job[T], state jobState) bool {
+	if !j.transition(state) {
+		return false
+	}
+	e.lock.Lock()
+	delete(e.unstarted, j)
+	e.lock.Unlock()
+	if state == jobDropped {
+		e.unqueue(j)
+	}
+	return true
+}
+
+// unqueue removes a dropped job from the workers queue, so it does not hold room that other jobs are waiting for
+func (e *Executor[T]) unqueue(j *job[T]) {
+	if e.queue != nil {
+		e.queue.remove(j)
+	}
+}
+
+// cancelJob handles the cancellation of a job future. A job that did not start yet is dropped right away, while a
+// running one has its context cancelled and is finished once its function returns
+func (e *Executor[T]) cancelJob(j *job[T]) {
+	e.cancelled.Add(1)
+	e.changes.notify()
+	if e.claim(j, jobDropped) {
+		e.finish(j, &result.Result[T]{Err: context.Canceled})
+		return
+	}
+	j.cancel()
+}
+
+// finish sends the job result and accounts for the job no longer being pending
+func (e *Executor[T]) finish(j *job[T], r *result.Result[T]) {
+	j.cancel()
+	j.future.complete(r)
+	e.statsLock.RLock()
+	// the future result may differ from r, for instance when the job timed out or was cancelled
+	if j.future.result.Load().Err != nil {
+		e.failed.Add(1)
+	}
+	// counted as done before it stops being pending, so that an idle executor has all its jobs accounted as done
+	e.done.Add(1)
+	pending := e.pending.Add(-1)
+	e.statsLock.RUnlock()
+	j.finished()
+	if pending == 0 {
+		e.checkTerminated()
+	}
+	e.changes.notify()
+}
+
+// Shutdown stops the executor from accepting new jobs and waits until all jobs already submitted are done.
+// Jobs submitted after Shutdown is called are rejected with ErrShutdown.
+//
+// Returns the context error if the context is done before all jobs finish. In that case the executor remains shut
+// down and keeps draining its jobs, and Shutdown or ShutdownNow can be called again
+func (e *Executor[T]) Shutdown(ctx context.Context) error {
+	e.lock.Lock()
+	e.shutdown = true
+	e.lock.Unlock()
+	e.checkTerminated()
+
+	select {
+	case <-e.terminated:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShutdownNow stops the executor from accepting new jobs and drops all jobs that did not start running yet, returning
+// them in submission order. The futures of dropped jobs hold ErrShutdown.
+//
+// The context of jobs that are already running is cancelled. Use Shutdown to wait for them to finish
+func (e *Executor[T]) ShutdownNow() []func(context.Context) (T, error) {
+	e.lock.Lock()
+	e.shutdown = true
+	dropped := make([]*job[T], 0, len(e.unstarted))
+	for j := range e.unstarted {
+		if j.transition(jobDropped) {
+			dropped = append(dropped, j)
+		}
+		delete(e.unstarted, j)
+	}
+	e.lock.Unlock()
+
+	// wake up jobs waiting to be enqueued or for their turn to run, and signal running jobs to stop
+	e.cancel()
+
+	sort.Slice(dropped, func(i, k int) bool { return dropped[i].seq < dropped[k].seq })
+	fns := make([]func(context.Context) (T, error), len(dropped))
+	for i, j := range dropped {
+		e.unqueue(j)
+		e.finish(j, &result.Result[T]{Err: ErrShutdown})
+		fns[i] = j.fn
+	}
+	e.checkTerminated()
+	return fns
+}
+
+// IsShutdown returns whether Shutdown or ShutdownNow was called
+func (e *Executor[T]) IsShutdown() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.shutdown
+}
+
+// IsTerminated returns whether the executor is shut down and all its jobs are done
+func (e *Executor[T]) IsTerminated() bool {
+	select {
+	case <-e.terminated:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Executor[T]) checkTerminated() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.shutdown || e.pending.Load() > 0 {
+		return
+	}
+	select {
+	case <-e.terminated:
+	default:
+		close(e.terminated)
+		e.cancel()
+	}
 }
 
 func (e *Executor[T]) MaxParallelism() int {
-	return e.maxParallelism
+	return int(e.maxParallelism.Load())
+}
+
+// SetMaxParallelism changes how many jobs can run at the same time. A maxParallelism of 0 means runtime.NumCPU(),
+// like in New.
+//
+// Growing it lets waiting jobs start right away. Shrinking it does not interrupt running jobs, but no new job starts
+// until the running ones are back under the new limit. Jobs whose weight is higher than the new limit take all the
+// parallelism slots when they run.
+//
+// Executors running with WithWorkers panic on negative values
+func (e *Executor[T]) SetMaxParallelism(maxParallelism int) {
+	if maxParallelism == 0 {
+		maxParallelism = runtime.NumCPU()
+	}
+	if e.queue != nil && maxParallelism < 0 {
+		panic("workers require a positive maxParallelism")
+	}
+	e.maxParallelism.Store(int64(maxParallelism))
+	e.limiter.resize(int64(maxParallelism))
+	if e.queue != nil {
+		for range e.queue.grow(maxParallelism) {
+			go e.work()
+		}
+	}
 }
 
 func (e *Executor[T]) Submitted() int64 {
@@ -109,6 +729,46 @@ func (e *Executor[T]) Done() int64 {
 	return e.done.Load()
 }
 
+// Attempts returns how many times job functions were called, including retries
+func (e *Executor[T]) Attempts() int64 {
+	return e.attempts.Load()
+}
+
+// Retries returns how many times job functions were called again after failing
+func (e *Executor[T]) Retries() int64 {
+	return e.retries.Load()
+}
+
+// TimedOut returns how many jobs did not finish within their timeout
+func (e *Executor[T]) TimedOut() int64 {
+	return e.timedOut.Load()
+}
+
+// Cancelled returns how many jobs had their futures cancelled before they were done
+func (e *Executor[T]) Cancelled() int64 {
+	return e.cancelled.Load()
+}
+
+// Deduplicated returns how many submissions got the future of another job submitted with the same WithDedupeKey
+func (e *Executor[T]) Deduplicated() int64 {
+	return e.deduplicated.Load()
+}
+
+// Failed returns how many jobs were done with an error
+func (e *Executor[T]) Failed() int64 {
+	return e.failed.Load()
+}
+
+// Throttled returns how many jobs had to wait for the rate limit before starting
+func (e *Executor[T]) Throttled() int64 {
+	return e.throttled.Load()
+}
+
+// ThrottledTime returns the total time jobs spent waiting for the rate limit
+func (e *Executor[T]) ThrottledTime() time.Duration {
+	return time.Duration(e.throttledTime.Load())
+}
+
 func (e *Executor[T]) Pending() int64 {
 	return e.pending.Load()
 }
@@ -117,23 +777,41 @@ func (e *Executor[T]) Active() bool {
 	return e.Pending() > 0
 }
 
+// Wait blocks until the executor is idle, with no pending jobs. See WaitIdle
 func (e *Executor[T]) Wait() {
-	e.waitInactiveLock.Lock()
-	e.waitInactiveCond.Wait()
-	e.waitInactiveLock.Unlock()
+	_ = e.WaitIdle(context.Background())
 }
 
+// WaitC is the same as Wait, but gives up when ctx is done, returning the context error. See WaitIdle
 func (e *Executor[T]) WaitC(ctx context.Context) error {
-	done := make(chan struct{})
-	go func() {
-		e.Wait()
-		close(done)
-	}()
+	return e.WaitIdle(ctx)
+}
 
-	select {
-	case <-done:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+// WaitIdle blocks until the executor has no pending jobs, returning right away if it is already idle.
+// Returns the context error if ctx is done first
+func (e *Executor[T]) WaitIdle(ctx context.Context) error {
+	return e.WaitFor(ctx, func(e *Executor[T]) bool { return e.Pending() == 0 })
+}
+
+// WaitFor blocks until predicate returns true, returning right away if it already does. The predicate is checked
+// again every time the executor counters change, so it can wait on thresholds like:
+//
+//	e.WaitFor(ctx, func(e *Executor[int]) bool { return e.Done() >= 100 })
+//
+// Returns the context error if ctx is done first
+func (e *Executor[T]) WaitFor(ctx context.Context, predicate func(*Executor[T]) bool) error {
+	e.changes.waiters.Add(1)
+	defer e.changes.waiters.Add(-1)
+	for {
+		// taking the channel before checking the predicate guarantees no change in between goes unnoticed
+		changed := e.changes.next()
+		if predicate(e) {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -322,3 +323,879 @@ func TestExecutorCollectPanicsOnBadArg(t *testing.T) {
 	test(map[any]any{})
 	test(make(chan any))
 }
+
+func TestExecutorWorkers(t *testing.T) {
+	parallelism := 5
+	length := 1000
+
+	e := New(parallelism, WithWorkers[int](0))
+
+	var currentParallelism atomic.Int32
+	var maxParallelismAchieved atomic.Int32
+	var maxWorkers atomic.Int32
+
+	futures := make([]*Future[int], length)
+	for i := range futures {
+		futures[i] = e.Submit(context.Background(), func() (int, error) {
+			current := currentParallelism.Add(1)
+			if current > maxParallelismAchieved.Load() {
+				maxParallelismAchieved.Store(current)
+			}
+			defer currentParallelism.Add(-1)
+			e.queue.lock.Lock()
+			if workers := int32(e.queue.workers); workers > maxWorkers.Load() {
+				maxWorkers.Store(workers)
+			}
+			e.queue.lock.Unlock()
+			time.Sleep(100 * time.Microsecond)
+			return i, nil
+		})
+	}
+
+	for i, f := range futures {
+		r := f.Get(context.Background())
+		require.NoError(t, r.Err)
+		require.Equal(t, i, r.Value)
+	}
+
+	require.LessOrEqual(t, maxParallelismAchieved.Load(), int32(parallelism))
+	require.LessOrEqual(t, maxWorkers.Load(), int32(parallelism))
+
+	// workers retire once the queue is drained
+	require.Eventually(t, func() bool {
+		e.queue.lock.Lock()
+		defer e.queue.lock.Unlock()
+		return e.Pending() == 0 && e.queue.workers == 0
+	}, 5*time.Second, time.Millisecond)
+
+	require.Equal(t, int64(length), e.Submitted())
+	require.Equal(t, int64(length), e.Launched())
+	require.Equal(t, int64(length), e.Done())
+	require.Equal(t, int64(0), e.InFlight())
+	require.Equal(t, int64(0), e.Pending())
+}
+
+func TestExecutorWorkersBoundedQueue(t *testing.T) {
+	e := New(1, WithWorkers[int](1))
+
+	release := make(chan struct{})
+	running := e.Submit(context.Background(), func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	// wait for the first job to leave the queue, so the next one fills it up
+	for e.InFlight() == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	queued := e.Submit(context.Background(), func() (int, error) { return 2, nil })
+
+	// the queue is full, so this submission blocks until the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	rejected := e.Submit(ctx, func() (int, error) { return 3, nil })
+	r, ok := rejected.GetNoBlock()
+	require.True(t, ok)
+	require.ErrorIs(t, r.Err, context.DeadlineExceeded)
+
+	close(release)
+	require.Equal(t, 1, running.Get(context.Background()).Must())
+	require.Equal(t, 2, queued.Get(context.Background()).Must())
+
+	require.Equal(t, int64(3), e.Submitted())
+	require.Equal(t, int64(2), e.Launched())
+	require.Eventually(t, func() bool { return e.Done() == 3 }, 5*time.Second, time.Millisecond)
+}
+
+func TestExecutorShutdown(t *testing.T) {
+	e := New[int](2)
+
+	release := make(chan struct{})
+	futures := Futures[int]{}
+	for i := 0; i < 5; i++ {
+		futures.Submit(context.Background(), e, func() (int, error) {
+			<-release
+			return i, nil
+		})
+	}
+
+	// shutdown times out while jobs are still running
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, e.Shutdown(ctx), context.DeadlineExceeded)
+	require.True(t, e.IsShutdown())
+	require.False(t, e.IsTerminated())
+
+	// new submissions are rejected
+	rejected := e.Submit(context.Background(), func() (int, error) { return 0, nil })
+	require.ErrorIs(t, rejected.Get(context.Background()).Err, ErrShutdown)
+	require.Equal(t, int64(5), e.Submitted())
+
+	// already submitted jobs are drained
+	close(release)
+	require.NoError(t, e.Shutdown(context.Background()))
+	require.True(t, e.IsTerminated())
+	require.Equal(t, []int{0, 1, 2, 3, 4}, futures.Get(context.Background()).Must())
+	require.Equal(t, int64(5), e.Done())
+	require.Equal(t, int64(0), e.Pending())
+}
+
+func TestExecutorShutdownNow(t *testing.T) {
+	test := func(t *testing.T, opts ...Option[int]) {
+		e := New(1, opts...)
+
+		release := make(chan struct{})
+		running := e.Submit(context.Background(), func() (int, error) {
+			<-release
+			return -1, nil
+		})
+		for e.InFlight() == 0 {
+			time.Sleep(1 * time.Millisecond)
+		}
+
+		futures := Futures[int]{}
+		for i := 0; i < 5; i++ {
+			futures.Submit(context.Background(), e, func() (int, error) { return i, nil })
+		}
+
+		unrun := e.ShutdownNow()
+		require.Len(t, unrun, 5)
+		for i, fn := range unrun {
+			v, err := fn(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, i, v)
+		}
+		for _, err := range futures.Get(context.Background()).Errors() {
+			require.ErrorIs(t, err, ErrShutdown)
+		}
+		require.True(t, e.IsShutdown())
+		require.False(t, e.IsTerminated())
+
+		// running jobs are not interrupted
+		close(release)
+		require.Equal(t, -1, running.Get(context.Background()).Must())
+		require.NoError(t, e.Shutdown(context.Background()))
+		require.True(t, e.IsTerminated())
+		require.Equal(t, int64(6), e.Submitted())
+		require.Equal(t, int64(6), e.Done())
+		require.Equal(t, int64(0), e.Pending())
+	}
+
+	t.Run("goroutines", func(t *testing.T) { test(t) })
+	t.Run("workers", func(t *testing.T) { test(t, WithWorkers[int](0)) })
+	t.Run("bounded workers", func(t *testing.T) { test(t, WithWorkers[int](5)) })
+}
+
+func TestExecutorPanic(t *testing.T) {
+	test := func(t *testing.T, opts ...Option[int]) {
+		e := New(1, opts...)
+
+		errPanic := errors.New("boom")
+		futures := Futures[int]{}
+		futures.Submit(context.Background(), e, func() (int, error) { panic("boom") })
+		futures.Submit(context.Background(), e, func() (int, error) { panic(errPanic) })
+		futures.Submit(context.Background(), e, func() (int, error) { return 1, nil })
+
+		results := futures.Get(context.Background())
+
+		var panicErr *PanicError
+		require.ErrorAs(t, results[0].Err, &panicErr)
+		require.Equal(t, "boom", panicErr.Value)
+		require.Contains(t, string(panicErr.Stack), "TestExecutorPanic")
+		require.EqualError(t, results[0].Err, "job panicked: boom")
+
+		require.ErrorAs(t, results[1].Err, &panicErr)
+		require.ErrorIs(t, results[1].Err, errPanic)
+
+		// the parallelism slot is released by panicking jobs, so the last job still runs
+		require.NoError(t, results[2].Err)
+		require.Equal(t, 1, results[2].Value)
+
+		require.Eventually(t, func() bool { return e.Done() == 3 }, 5*time.Second, time.Millisecond)
+		require.Equal(t, int64(0), e.InFlight())
+		require.Equal(t, int64(0), e.Pending())
+	}
+
+	t.Run("goroutines", func(t *testing.T) { test(t) })
+	t.Run("workers", func(t *testing.T) { test(t, WithWorkers[int](0)) })
+}
+
+func TestExecutorSubmitCtx(t *testing.T) {
+	e := New[int](2)
+
+	// the job context is cancelled when the submission context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	cancelled := e.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+	cancel()
+	require.ErrorIs(t, cancelled.Get(context.Background()).Err, context.Canceled)
+
+	// the job context carries the submission context values
+	type key struct{}
+	ctx = context.WithValue(context.Background(), key{}, 10)
+	valued := e.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+		return ctx.Value(key{}).(int), nil
+	})
+	require.Equal(t, 10, valued.Get(context.Background()).Must())
+
+	// the job context is cancelled when the executor is shut down
+	started = make(chan struct{})
+	shutdown := e.SubmitCtx(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+	require.Empty(t, e.ShutdownNow())
+	require.ErrorIs(t, shutdown.Get(context.Background()).Err, context.Canceled)
+}
+
+func TestExecutorCollectCtx(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, 10)
+
+	inputs := []int{1, 2, 3}
+	outputs := CollectCtx(ctx, 2, inputs, func(ctx context.Context, i int) (int, error) {
+		return inputs[i] * ctx.Value(key{}).(int), nil
+	})
+	require.Equal(t, []int{10, 20, 30}, outputs.Must())
+
+	outputsMap := CollectMapCtx(ctx, 2, inputs, func(ctx context.Context, k int) (int, error) {
+		return k * ctx.Value(key{}).(int), nil
+	})
+	require.Equal(t, map[int]int{1: 10, 2: 20, 3: 30}, outputsMap.Values())
+	require.NoError(t, outputsMap.Error())
+}
+
+func TestExecutorCollectSlice(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	inputs := []string{"a", "bb", "ccc"}
+	outputs := CollectSlice(ctx, 2, inputs, func(i int, s string) (string, error) {
+		return fmt.Sprintf("%d:%s", i, s), nil
+	})
+	require.Equal(t, []string{"0:a", "1:bb", "2:ccc"}, outputs.Must())
+
+	lengths := CollectSliceE(ctx, New[int](2), inputs, func(i int, s string) (int, error) {
+		if i == 1 {
+			return 0, errBoom
+		}
+		return len(s), nil
+	})
+	require.Equal(t, []int{1, 0, 3}, lengths.Values())
+	require.Equal(t, []error{nil, errBoom, nil}, lengths.Errors())
+
+	require.Empty(t, CollectSlice(ctx, 2, []int(nil), func(i int, v int) (int, error) { return v, nil }))
+
+	type key struct{}
+	ctx = context.WithValue(ctx, key{}, 10)
+	scaled := CollectSliceCtx(ctx, 2, []int{1, 2}, func(ctx context.Context, _ int, v int) (int, error) {
+		return v * ctx.Value(key{}).(int), nil
+	})
+	require.Equal(t, []int{10, 20}, scaled.Must())
+}
+
+func TestExecutorCollectEntries(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	entries := map[string]int{"a": 1, "b": 2, "c": 3}
+	outputs := CollectEntries(ctx, 2, entries, func(k string, v int) (string, error) {
+		if k == "b" {
+			return "", errBoom
+		}
+		return fmt.Sprintf("%s=%d", k, v), nil
+	})
+	require.Equal(t, map[string]string{"a": "a=1", "c": "c=3"}, outputs.ValuesOnly())
+	require.Equal(t, map[string]error{"b": errBoom}, outputs.ErrorsOnly())
+
+	require.Empty(t, CollectEntries(ctx, 2, map[string]int{}, func(k string, v int) (int, error) { return v, nil }))
+
+	type key struct{}
+	ctx = context.WithValue(ctx, key{}, 10)
+	scaled := CollectEntriesCtx(ctx, 2, entries, func(ctx context.Context, _ string, v int) (int, error) {
+		return v * ctx.Value(key{}).(int), nil
+	})
+	require.Equal(t, map[string]int{"a": 10, "b": 20, "c": 30}, scaled.Values())
+}
+
+func TestExecutorCollectFailFast(t *testing.T) {
+	errBoom := errors.New("boom")
+	length := 100
+
+	test := func(t *testing.T, results result.Results[int], calls int64) {
+		require.Less(t, calls, int64(length))
+		require.Equal(t, errBoom, results[3].Err)
+		for i, r := range results {
+			if i != 3 && r.Err != nil {
+				require.ErrorIs(t, r.Err, context.Canceled)
+			}
+		}
+		var resultsErr *result.ResultsError
+		require.ErrorAs(t, results.Error(), &resultsErr)
+		require.Equal(t, errBoom, resultsErr.Errors[0])
+		require.Greater(t, len(resultsErr.Errors), 1)
+	}
+
+	fn := func(calls *atomic.Int64) func(context.Context, int) (int, error) {
+		return func(ctx context.Context, i int) (int, error) {
+			calls.Add(1)
+			if i == 3 {
+				return 0, errBoom
+			}
+			select {
+			case <-time.After(time.Duration(i) * time.Millisecond):
+				return i, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+	}
+
+	t.Run("slice", func(t *testing.T) {
+		var calls atomic.Int64
+		results := CollectFailFast(context.Background(), 2, make([]int, length), fn(&calls))
+		test(t, results, calls.Load())
+	})
+
+	t.Run("map", func(t *testing.T) {
+		var calls atomic.Int64
+		keys := make([]int, length)
+		for i := range keys {
+			keys[i] = i
+		}
+		resultsMap := CollectMapFailFast(context.Background(), 2, keys, fn(&calls))
+		results := make(result.Results[int], length)
+		for k, r := range resultsMap {
+			results[k] = r
+		}
+		test(t, results, calls.Load())
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		// workers pick up jobs in submission order, so the first job is guaranteed to run first
+		e := New(1, WithWorkers[int](0))
+		results := CollectFailFastE(context.Background(), e, make([]int, length), func(ctx context.Context, i int) (int, error) {
+			if i == 0 {
+				panic("boom")
+			}
+			return i, nil
+		})
+		var panicErr *PanicError
+		require.ErrorAs(t, results[0].Err, &panicErr)
+		require.ErrorIs(t, results[length-1].Err, context.Canceled)
+	})
+}
+
+func TestExecutorTimeout(t *testing.T) {
+	e := New(2, WithTimeout[int](20*time.Millisecond))
+
+	// jobs that respect their context
+	f := e.SubmitCtx(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	var timeoutErr *TimeoutError
+	err := f.Get(context.Background()).Err
+	require.ErrorAs(t, err, &timeoutErr)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 20*time.Millisecond, timeoutErr.Timeout)
+
+	// jobs that ignore their context still have their futures completed on time
+	release := make(chan struct{})
+	f = e.Submit(context.Background(), func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	require.ErrorAs(t, f.Get(context.Background()).Err, &timeoutErr)
+	require.True(t, f.IsDone())
+	require.Equal(t, int64(1), e.InFlight())
+	close(release)
+	require.Eventually(t, func() bool { return e.Done() == 2 }, 5*time.Second, time.Millisecond)
+	require.ErrorAs(t, f.Get(context.Background()).Err, &timeoutErr)
+
+	// the executor timeout can be overridden per submission
+	f = e.Submit(context.Background(), func() (int, error) {
+		time.Sleep(40 * time.Millisecond)
+		return 2, nil
+	}, WithJobTimeout(0))
+	require.Equal(t, 2, f.Get(context.Background()).Must())
+
+	// the submission context deadline is not a job timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	f = e.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	err = f.Get(context.Background()).Err
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotErrorAs(t, err, &timeoutErr)
+
+	require.Equal(t, int64(2), e.TimedOut())
+}
+
+func TestExecutorPriority(t *testing.T) {
+	test := func(t *testing.T, waiting func(e *Executor[int]) int, opts ...Option[int]) {
+		e := New(1, opts...)
+
+		release := make(chan struct{})
+		e.Submit(context.Background(), func() (int, error) {
+			<-release
+			return 0, nil
+		})
+		require.Eventually(t, func() bool { return e.InFlight() == 1 }, 5*time.Second, time.Millisecond)
+
+		var lock sync.Mutex
+		order := []int{}
+		futures := Futures[int]{}
+		for i := 0; i < 10; i++ {
+			priority := 0
+			if i%2 == 1 {
+				priority = 10
+			}
+			futures.Add(e.SubmitWithPriority(context.Background(), priority, func(context.Context) (int, error) {
+				lock.Lock()
+				defer lock.Unlock()
+				order = append(order, i)
+				return i, nil
+			}))
+		}
+		require.Eventually(t, func() bool { return waiting(e) == 10 }, 5*time.Second, time.Millisecond)
+
+		close(release)
+		futures.Get(context.Background())
+		require.Equal(t, []int{1, 3, 5, 7, 9, 0, 2, 4, 6, 8}, order)
+	}
+
+	t.Run("goroutines", func(t *testing.T) {
+		test(t, func(e *Executor[int]) int {
+			e.limiter.lock.Lock()
+			defer e.limiter.lock.Unlock()
+			return len(e.limiter.waiters)
+		})
+	})
+	t.Run("workers", func(t *testing.T) {
+		test(t, func(e *Executor[int]) int {
+			e.queue.lock.Lock()
+			defer e.queue.lock.Unlock()
+			return len(e.queue.jobs)
+		}, WithWorkers[int](0))
+	})
+}
+
+func TestExecutorWeight(t *testing.T) {
+	test := func(t *testing.T, opts ...Option[int]) {
+		parallelism := 4
+		e := New(parallelism, opts...)
+
+		var currentWeight atomic.Int32
+		var maxWeightAchieved atomic.Int32
+		futures := Futures[int]{}
+		for i := 0; i < 100; i++ {
+			weight := 1
+			if i%5 == 0 {
+				weight = parallelism
+			}
+			futures.Add(e.Submit(context.Background(), func() (int, error) {
+				current := currentWeight.Add(int32(weight))
+				defer currentWeight.Add(-int32(weight))
+				for {
+					achieved := maxWeightAchieved.Load()
+					if current <= achieved || maxWeightAchieved.CompareAndSwap(achieved, current) {
+						break
+					}
+				}
+				time.Sleep(100 * time.Microsecond)
+				return weight, nil
+			}, WithWeight(weight)))
+		}
+		require.NoError(t, futures.Get(context.Background()).Error())
+		require.LessOrEqual(t, maxWeightAchieved.Load(), int32(parallelism))
+
+		for _, weight := range []int{0, -1, parallelism + 1} {
+			f := e.Submit(context.Background(), func() (int, error) { return 0, nil }, WithWeight(weight))
+			require.ErrorIs(t, f.Get(context.Background()).Err, ErrInvalidWeight)
+		}
+		require.Equal(t, int64(100), e.Submitted())
+	}
+
+	t.Run("goroutines", func(t *testing.T) { test(t) })
+	t.Run("workers", func(t *testing.T) { test(t, WithWorkers[int](0)) })
+}
+
+func TestExecutorSetMaxParallelism(t *testing.T) {
+	test := func(t *testing.T, opts ...Option[int]) {
+		e := New(1, opts...)
+
+		release := make(chan struct{})
+		futures := Futures[int]{}
+		for i := 0; i < 10; i++ {
+			futures.Submit(context.Background(), e, func() (int, error) {
+				<-release
+				return i, nil
+			})
+		}
+		require.Eventually(t, func() bool { return e.InFlight() == 1 }, 5*time.Second, time.Millisecond)
+
+		// growing lets waiting jobs start
+		e.SetMaxParallelism(4)
+		require.Equal(t, 4, e.MaxParallelism())
+		require.Eventually(t, func() bool { return e.InFlight() == 4 }, 5*time.Second, time.Millisecond)
+
+		// shrinking holds back new starts until running jobs are under the new limit
+		e.SetMaxParallelism(2)
+		for i := 0; i < 3; i++ {
+			release <- struct{}{}
+		}
+		require.Eventually(t, func() bool { return e.Done() == 3 }, 5*time.Second, time.Millisecond)
+		require.Eventually(t, func() bool { return e.InFlight() == 2 }, 5*time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, int64(2), e.InFlight())
+
+		close(release)
+		require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, futures.Get(context.Background()).Must())
+		require.Equal(t, int64(10), e.Submitted())
+		require.Eventually(t, func() bool { return e.Done() == 10 }, 5*time.Second, time.Millisecond)
+	}
+
+	t.Run("goroutines", func(t *testing.T) { test(t) })
+	t.Run("workers", func(t *testing.T) { test(t, WithWorkers[int](0)) })
+
+	t.Run("weight over the new limit", func(t *testing.T) {
+		e := New[int](4)
+		release := make(chan struct{})
+		blocker := e.Submit(context.Background(), func() (int, error) {
+			<-release
+			return 0, nil
+		})
+		require.Eventually(t, func() bool { return e.InFlight() == 1 }, 5*time.Second, time.Millisecond)
+		heavy := e.Submit(context.Background(), func() (int, error) { return 4, nil }, WithWeight(4))
+		e.SetMaxParallelism(2)
+		close(release)
+		require.Equal(t, 0, blocker.Get(context.Background()).Must())
+		require.Equal(t, 4, heavy.Get(context.Background()).Must())
+	})
+}
+
+func TestExecutorRateLimit(t *testing.T) {
+	e := New(4, WithRateLimit[int](200, 2))
+
+	start := time.Now()
+	futures := Futures[int]{}
+	for i := 0; i < 12; i++ {
+		futures.Submit(context.Background(), e, func() (int, error) { return i, nil })
+	}
+	require.NoError(t, futures.Get(context.Background()).Error())
+
+	// 2 jobs start right away thanks to the burst, the other 10 are spaced 5ms apart
+	require.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+	require.GreaterOrEqual(t, e.Throttled(), int64(9))
+	require.GreaterOrEqual(t, e.ThrottledTime(), 45*time.Millisecond)
+
+	// waiting for the rate limit honors the submission context, and gives back the parallelism slot
+	e = New(1, WithRateLimit[int](1, 1))
+	require.Equal(t, 1, e.Submit(context.Background(), func() (int, error) { return 1, nil }).Get(context.Background()).Must())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	f := e.Submit(ctx, func() (int, error) { return 2, nil })
+	require.ErrorIs(t, f.Get(context.Background()).Err, context.DeadlineExceeded)
+	require.Equal(t, int64(1), e.Throttled())
+	require.Equal(t, int64(0), e.InFlight())
+	require.Equal(t, int64(1), e.Attempts())
+}
+
+func TestExecutorCancel(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("waiting job", func(t *testing.T) {
+		e := New[int](1)
+		release := make(chan struct{})
+		running := e.Submit(ctx, func() (int, error) { <-release; return 1, nil })
+		called := false
+		waiting := e.Submit(ctx, func() (int, error) { called = true; return 2, nil })
+
+		require.True(t, waiting.Cancel())
+		require.False(t, waiting.Cancel())
+		require.True(t, waiting.IsDone())
+		require.Equal(t, Cancelled, waiting.State())
+		require.ErrorIs(t, waiting.Get(ctx).Err, context.Canceled)
+		require.Equal(t, Cancelled, waiting.State())
+
+		close(release)
+		require.Equal(t, 1, running.Get(ctx).Must())
+		require.Eventually(t, func() bool { return e.Pending() == 0 }, time.Second, time.Millisecond)
+		require.False(t, called)
+		require.Equal(t, int64(1), e.Cancelled())
+		require.Equal(t, int64(1), e.Attempts())
+	})
+
+	t.Run("queued job", func(t *testing.T) {
+		e := New(1, WithWorkers[int](1))
+		release := make(chan struct{})
+		running := e.Submit(ctx, func() (int, error) { <-release; return 1, nil })
+		for e.InFlight() == 0 {
+			time.Sleep(1 * time.Millisecond)
+		}
+		waiting := e.Submit(ctx, func() (int, error) { return 2, nil })
+		require.True(t, waiting.Cancel())
+		require.Equal(t, int64(1), e.Pending())
+
+		// the cancelled job frees its room in the queue right away
+		submitted := make(chan *Future[int])
+		go func() { submitted <- e.Submit(ctx, func() (int, error) { return 3, nil }) }()
+		var next *Future[int]
+		select {
+		case next = <-submitted:
+		case <-time.After(time.Second):
+			require.FailNow(t, "submit blocked by the cancelled job")
+		}
+
+		// the cancelled job no longer counts as unstarted, and dropped jobs leave the queue
+		require.Len(t, e.ShutdownNow(), 1)
+		require.ErrorIs(t, next.Get(ctx).Err, ErrShutdown)
+		e.queue.lock.Lock()
+		require.Empty(t, e.queue.jobs)
+		e.queue.lock.Unlock()
+
+		close(release)
+		require.NoError(t, e.Shutdown(ctx))
+		require.Equal(t, 1, running.Get(ctx).Must())
+		require.Equal(t, int64(1), e.Attempts())
+		require.Equal(t, int64(1), e.Launched())
+	})
+
+	t.Run("running job", func(t *testing.T) {
+		e := New[int](1)
+		started := make(chan struct{})
+		stopped := make(chan struct{})
+		f := e.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+			close(started)
+			<-ctx.Done()
+			close(stopped)
+			return 0, ctx.Err()
+		})
+		<-started
+		require.True(t, f.Cancel())
+		require.ErrorIs(t, f.Get(ctx).Err, context.Canceled)
+		<-stopped
+		require.Eventually(t, func() bool { return e.Pending() == 0 }, time.Second, time.Millisecond)
+		require.Equal(t, Cancelled, f.State())
+		require.Equal(t, int64(1), e.Cancelled())
+	})
+
+	t.Run("done job", func(t *testing.T) {
+		e := New[int](1)
+		f := e.Submit(ctx, func() (int, error) { return 1, nil })
+		require.Equal(t, 1, f.Get(ctx).Must())
+		require.False(t, f.Cancel())
+		require.Equal(t, ResultStored, f.State())
+		require.Equal(t, int64(0), e.Cancelled())
+	})
+}
+
+func TestExecutorDedupe(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	t.Run("pending jobs are shared", func(t *testing.T) {
+		e := New[int](-1)
+		var calls atomic.Int64
+		release := make(chan struct{})
+		fn := func() (int, error) {
+			calls.Add(1)
+			<-release
+			return 1, nil
+		}
+		first := e.Submit(ctx, fn, WithDedupeKey("a"))
+		require.Same(t, first, e.Submit(ctx, fn, WithDedupeKey("a")))
+		other := e.Submit(ctx, fn, WithDedupeKey("b"))
+		require.NotSame(t, first, other)
+		close(release)
+		require.Equal(t, 1, first.Get(ctx).Must())
+		require.Equal(t, 1, other.Get(ctx).Must())
+		require.Equal(t, int64(2), calls.Load())
+		require.Equal(t, int64(1), e.Deduplicated())
+
+		// without a ttl, completed results are forgotten right away
+		require.Equal(t, 1, e.Submit(ctx, fn, WithDedupeKey("a")).Get(ctx).Must())
+		require.Equal(t, int64(3), calls.Load())
+	})
+
+	t.Run("results are kept for the ttl", func(t *testing.T) {
+		e := New(-1, WithDedupeTTL[int](30*time.Millisecond))
+		var calls atomic.Int64
+		fn := func() (int, error) { return int(calls.Add(1)), nil }
+
+		first := e.Submit(ctx, fn, WithDedupeKey("a"))
+		require.Equal(t, 1, first.Get(ctx).Must())
+		require.Same(t, first, e.Submit(ctx, fn, WithDedupeKey("a")))
+		var second *Future[int]
+		require.Eventually(t, func() bool {
+			second = e.Submit(ctx, fn, WithDedupeKey("a"))
+			return second != first
+		}, time.Second, time.Millisecond)
+		require.Equal(t, 2, second.Get(ctx).Must())
+
+		// failures are not kept
+		failed := e.Submit(ctx, func() (int, error) { return 0, errBoom }, WithDedupeKey("b"))
+		require.ErrorIs(t, failed.Get(ctx).Err, errBoom)
+		require.Eventually(t, func() bool {
+			return e.Submit(ctx, fn, WithDedupeKey("b")) != failed
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestExecutorWaitIdle(t *testing.T) {
+	ctx := context.Background()
+	e := New[int](2)
+
+	// an idle executor does not block
+	require.NoError(t, e.WaitIdle(ctx))
+	e.Wait()
+	require.NoError(t, e.WaitC(ctx))
+
+	release := make(chan struct{})
+	for i := range 10 {
+		e.Submit(ctx, func() (int, error) {
+			<-release
+			return i, nil
+		})
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, e.WaitIdle(timeoutCtx), context.DeadlineExceeded)
+	require.NoError(t, e.WaitFor(ctx, func(e *Executor[int]) bool { return e.InFlight() == 2 }))
+
+	waited := make(chan error)
+	go func() { waited <- e.WaitIdle(ctx) }()
+	for range 4 {
+		release <- struct{}{}
+	}
+	require.NoError(t, e.WaitFor(ctx, func(e *Executor[int]) bool { return e.Done() >= 4 }))
+	require.GreaterOrEqual(t, e.Pending(), int64(6))
+	close(release)
+	require.NoError(t, <-waited)
+	require.Equal(t, int64(10), e.Done())
+	require.Equal(t, int64(0), e.Pending())
+}
+
+func TestExecutorStats(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	e := New[int](2)
+
+	stats := e.Stats()
+	require.Equal(t, 2, stats.MaxParallelism)
+	require.Equal(t, int64(0), stats.Submitted)
+	require.Equal(t, 0, stats.RunTime.Entries)
+	require.Equal(t, int64(0), stats.RunTimeHistogram.Count)
+
+	futures := Futures[int]{}
+	for i := range 6 {
+		futures.Submit(ctx, e, func() (int, error) {
+			time.Sleep(10 * time.Millisecond)
+			if i%3 == 0 {
+				return 0, errBoom
+			}
+			return i, nil
+		})
+	}
+
+	// counters are consistent with each other while jobs run
+	for range 20 {
+		stats := e.Stats()
+		require.Equal(t, stats.Submitted, stats.Done+stats.Pending)
+		require.LessOrEqual(t, stats.InFlight, stats.PeakInFlight)
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, e.WaitIdle(ctx))
+
+	stats = e.Stats()
+	require.Equal(t, int64(6), stats.Submitted)
+	require.Equal(t, int64(6), stats.Done)
+	require.Equal(t, int64(2), stats.Failed)
+	require.Equal(t, int64(2), stats.PeakInFlight)
+	require.Greater(t, stats.Throughput, 0.0)
+	require.Equal(t, 6, stats.RunTime.Entries)
+	require.GreaterOrEqual(t, stats.RunTime.Percentiles[50], 0.01)
+	require.Equal(t, 6, stats.QueueWait.Entries)
+	// the last jobs had to wait for 2 rounds of jobs to run
+	require.GreaterOrEqual(t, stats.QueueWait.Max, 0.02)
+	require.Less(t, stats.QueueWait.Min, 0.01)
+
+	require.Equal(t, int64(6), stats.RunTimeHistogram.Count)
+	require.GreaterOrEqual(t, stats.RunTimeHistogram.Sum, 0.06)
+	require.Equal(t, 0.005, stats.RunTimeHistogram.Buckets[1].UpperBound)
+	require.Equal(t, int64(0), stats.RunTimeHistogram.Buckets[1].Count)
+	require.Equal(t, int64(6), stats.RunTimeHistogram.Buckets[len(stats.RunTimeHistogram.Buckets)-1].Count)
+}
+
+func TestExecutorInterceptors(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	type key struct{}
+	var lock sync.Mutex
+	calls := []string{}
+	record := func(name string) Interceptor[int] {
+		return func(ctx context.Context, next func(context.Context) (int, error)) (int, error) {
+			lock.Lock()
+			calls = append(calls, "before "+name)
+			lock.Unlock()
+			res, err := next(context.WithValue(ctx, key{}, name))
+			lock.Lock()
+			calls = append(calls, fmt.Sprintf("after %s %d %v", name, res, err))
+			lock.Unlock()
+			return res, err
+		}
+	}
+	double := func(ctx context.Context, next func(context.Context) (int, error)) (int, error) {
+		res, err := next(ctx)
+		return res * 2, err
+	}
+
+	attempts := 0
+	e := New(1, WithInterceptors(record("outer"), double), WithInterceptors(record("inner")), WithRetry[int](RetryPolicy{MaxAttempts: 2}))
+	f := e.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+		attempts++
+		if attempts == 1 {
+			return 0, errBoom
+		}
+		// the innermost interceptor context reaches the job
+		require.Equal(t, "inner", ctx.Value(key{}))
+		return 21, nil
+	})
+	require.Equal(t, 42, f.Get(ctx).Must())
+	// retries happen inside the interceptors
+	require.Equal(t, []string{"before outer", "before inner", "after inner 21 <nil>", "after outer 42 <nil>"}, calls)
+
+	// interceptors apply to the collect helpers too
+	calls = []string{}
+	results := CollectSliceE(ctx, e, []int{1, 2}, func(_ int, v int) (int, error) { return v, nil })
+	require.Equal(t, []int{2, 4}, results.Must())
+	require.Len(t, calls, 8)
+
+	// panics in jobs reach interceptors as errors, and panics in interceptors are recovered
+	var seen error
+	e = New(1, WithInterceptors(func(ctx context.Context, next func(context.Context) (int, error)) (int, error) {
+		res, err := next(ctx)
+		seen = err
+		return res, err
+	}))
+	var panicErr *PanicError
+	require.ErrorAs(t, e.Submit(ctx, func() (int, error) { panic("job") }).Get(ctx).Err, &panicErr)
+	require.ErrorAs(t, seen, &panicErr)
+
+	e = New(1, WithInterceptors(func(context.Context, func(context.Context) (int, error)) (int, error) {
+		panic("interceptor")
+	}))
+	require.ErrorAs(t, e.Submit(ctx, func() (int, error) { return 1, nil }).Get(ctx).Err, &panicErr)
+	require.Equal(t, "interceptor", panicErr.Value)
+}
@@ -2,10 +2,10 @@ package executor
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 
 	"github.com/bcap/go-lib/result"
-	"golang.org/x/sync/semaphore"
 )
 
 type FutureState int32
@@ -15,19 +15,24 @@ const (
 	Executing         FutureState = 1
 	ResultReady       FutureState = 2
 	ResultStored      FutureState = 3
+	Cancelled         FutureState = 4
 )
 
 type Future[T any] struct {
-	resultC     chan *result.Result[T]
-	result      atomic.Value
-	resultFetch semaphore.Weighted
-	state       atomic.Int32
+	done     chan struct{}
+	result   atomic.Pointer[result.Result[T]]
+	state    atomic.Int32
+	attempts atomic.Int32
+
+	callbacksLock sync.Mutex
+	callbacks     []func(*result.Result[T])
+
+	onCancel atomic.Pointer[func()] // set by the executor running the job, if any. See setOnCancel
 }
 
 func newFuture[T any]() *Future[T] {
 	return &Future[T]{
-		resultC:     make(chan *result.Result[T], 1),
-		resultFetch: *semaphore.NewWeighted(1),
+		done: make(chan struct{}),
 	}
 }
 
@@ -41,55 +46,100 @@ func (f *Future[T]) GetNoBlock() (*result.Result[T], bool) {
 }
 
 func (f *Future[T]) get(ctx context.Context, block bool) *result.Result[T] {
-	res := f.result.Load()
-	if res != nil {
-		return res.(*result.Result[T])
-	}
-
-	// Only one goroutine can fetch the result from the channel
-	// All others wait on its result
-	if err := f.resultFetch.Acquire(ctx, 1); err != nil {
-		return &result.Result[T]{Err: err}
-	}
-	defer f.resultFetch.Release(1)
-
-	// double checked locking
-	res = f.result.Load()
-	if res != nil {
-		return res.(*result.Result[T])
-	}
-
-	var r *result.Result[T]
-	if block {
+	select {
+	case <-f.done:
+	default:
+		if !block {
+			return nil
+		}
 		select {
-		case r = <-f.resultC:
+		case <-f.done:
 		case <-ctx.Done():
 			return &result.Result[T]{Err: ctx.Err()}
 		}
-	} else {
-		select {
-		case r = <-f.resultC:
-		default:
-			return nil
-		}
 	}
-
-	f.result.Store(r)
-	f.state.Store(int32(ResultStored))
-	return r
+	f.state.CompareAndSwap(int32(ResultReady), int32(ResultStored))
+	return f.result.Load()
 }
 
 func (f *Future[T]) State() FutureState {
 	return FutureState(f.state.Load())
 }
 
+// Attempts returns how many times the job function was called so far, including retries
+func (f *Future[T]) Attempts() int {
+	return int(f.attempts.Load())
+}
+
 func (f *Future[T]) setState(state FutureState) {
 	f.state.Store(int32(state))
 }
 
+// complete sets the future result and runs the registered callbacks.
+// Only the first call has any effect, later ones return false
+func (f *Future[T]) complete(r *result.Result[T]) bool {
+	return f.completeAs(r, ResultReady)
+}
+
+func (f *Future[T]) completeAs(r *result.Result[T], state FutureState) bool {
+	if !f.result.CompareAndSwap(nil, r) {
+		return false
+	}
+	f.setState(state)
+	close(f.done)
+
+	f.callbacksLock.Lock()
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.callbacksLock.Unlock()
+	for _, callback := range callbacks {
+		callback(r)
+	}
+	return true
+}
+
+// OnComplete registers a callback to be called with the future result once it completes. If the future is already
+// completed the callback is called right away, in the calling goroutine.
+//
+// Callbacks run in the goroutine completing the future, in registration order, so they should be quick and not block
+func (f *Future[T]) OnComplete(callback func(*result.Result[T])) {
+	f.callbacksLock.Lock()
+	if r := f.result.Load(); r != nil {
+		f.callbacksLock.Unlock()
+		callback(r)
+		return
+	}
+	f.callbacks = append(f.callbacks, callback)
+	f.callbacksLock.Unlock()
+}
+
 func (f *Future[T]) IsDone() bool {
 	state := f.State()
-	return state == ResultReady || state == ResultStored
+	return state == ResultReady || state == ResultStored || state == Cancelled
+}
+
+// Cancel completes the future with context.Canceled and moves it to the Cancelled state.
+//
+// If the job behind the future did not start yet, it never runs. If it is already running, its context is cancelled
+// so it can stop early. Returns false if the future was already done, in which case nothing changes
+func (f *Future[T]) Cancel() bool {
+	if !f.completeAs(&result.Result[T]{Err: context.Canceled}, Cancelled) {
+		return false
+	}
+	if onCancel := f.onCancel.Swap(nil); onCancel != nil {
+		(*onCancel)()
+	}
+	return true
+}
+
+// setOnCancel sets the function that cancels the job behind the future. If the future was already cancelled, which
+// can happen when it is handed to the executor after being returned to the caller, the function is called right
+// away. Either way it is called at most once
+func (f *Future[T]) setOnCancel(onCancel func()) {
+	f.onCancel.Store(&onCancel)
+	if f.State() == Cancelled && f.onCancel.CompareAndSwap(&onCancel, nil) {
+		onCancel()
+	}
 }
 
 type Futures[T any] []*Future[T]
@@ -100,6 +150,12 @@ func (fs *Futures[T]) Submit(ctx context.Context, e *Executor[T], fn func() (T,
 	return future
 }
 
+func (fs *Futures[T]) SubmitCtx(ctx context.Context, e *Executor[T], fn func(context.Context) (T, error)) *Future[T] {
+	future := e.SubmitCtx(ctx, fn)
+	fs.Add(future)
+	return future
+}
+
 func (fs *Futures[T]) Add(f *Future[T]) {
 	*fs = append(*fs, f)
 }
@@ -3,7 +3,9 @@ module github.com/bcap/go-lib/executor
 go 1.25.2
 
 require (
-	github.com/bcap/go-lib/result v0.1.1
+	github.com/bcap/go-lib/collection v0.1.0
+	github.com/bcap/go-lib/numstat v0.1.0
+	github.com/bcap/go-lib/result v0.1.2
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/sync v0.8.0
 )
@@ -0,0 +1,33 @@
+package executor
+
+import "context"
+
+// Interceptor wraps the execution of every job of an executor, like an HTTP middleware. It receives the job context
+// and next, which runs the rest of the chain and ultimately the job function, and returns the job result. It can
+// act before and after the job, change its context, or change its result. Example:
+//
+//	logging := func(ctx context.Context, next func(context.Context) (int, error)) (int, error) {
+//		start := time.Now()
+//		res, err := next(ctx)
+//		log.Printf("job took %v: %v", time.Since(start), err)
+//		return res, err
+//	}
+//	e := New(4, WithInterceptors(logging))
+//
+// Interceptors wrap the job as a whole: retries happen inside next, and timeouts cancel the context given to them.
+// Panics in the job function reach interceptors as a PanicError, and panics in interceptors are turned into a
+// PanicError as well
+type Interceptor[T any] func(ctx context.Context, next func(context.Context) (T, error)) (T, error)
+
+// intercept runs the job through the executor interceptors
+func (e *Executor[T]) intercept(ctx context.Context, j *job[T]) (T, error) {
+	if len(e.interceptors) == 0 {
+		return e.execute(ctx, j)
+	}
+	next := func(ctx context.Context) (T, error) { return e.execute(ctx, j) }
+	for i := len(e.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := e.interceptors[i], next
+		next = func(ctx context.Context) (T, error) { return interceptor(ctx, inner) }
+	}
+	return call(ctx, next)
+}
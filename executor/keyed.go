@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bcap/go-lib/result"
+)
+
+// KeyedExecutor runs jobs serialized by key: jobs submitted with the same key run one at a time, in submission
+// order, while jobs with different keys run in parallel, bounded by the maxParallelism of the underlying Executor.
+//
+// Jobs waiting for a previous job with the same key do not take parallelism slots nor queue space in the Executor:
+// they are only submitted to it once their turn comes.
+//
+// A job turn ends once its function returns. Jobs that time out or are cancelled while running complete their futures
+// right away, but the next job for the same key still waits for their function to return
+type KeyedExecutor[K comparable, T any] struct {
+	executor *Executor[T]
+	lock     sync.Mutex
+	tails    map[K]*Future[struct{}] // turn of the last job submitted for each key
+	waiting  atomic.Int64
+}
+
+// NewKeyed creates a KeyedExecutor backed by a new Executor created with the given maxParallelism and options
+func NewKeyed[K comparable, T any](maxParallelism int, opts ...Option[T]) *KeyedExecutor[K, T] {
+	return NewKeyedE[K](New(maxParallelism, opts...))
+}
+
+// NewKeyedE is the same as NewKeyed, but you can pass an existing Executor
+func NewKeyedE[K comparable, T any](e *Executor[T]) *KeyedExecutor[K, T] {
+	return &KeyedExecutor[K, T]{
+		executor: e,
+		tails:    map[K]*Future[struct{}]{},
+	}
+}
+
+// Executor returns the underlying Executor, which holds the counters of the jobs that already got their turn
+func (k *KeyedExecutor[K, T]) Executor() *Executor[T] {
+	return k.executor
+}
+
+// Waiting returns how many jobs are waiting for a previous job with the same key to finish
+func (k *KeyedExecutor[K, T]) Waiting() int64 {
+	return k.waiting.Load()
+}
+
+// Submit runs fn once all jobs previously submitted with the same key are done
+func (k *KeyedExecutor[K, T]) Submit(ctx context.Context, key K, fn func() (T, error), opts ...SubmitOption) *Future[T] {
+	return k.SubmitCtx(ctx, key, func(context.Context) (T, error) { return fn() }, opts...)
+}
+
+// SubmitCtx is the same as Submit, but the function receives the job context. See Executor.SubmitCtx.
+//
+// The returned future is the one of the job in the underlying Executor, so its state and attempts are tracked as
+// usual once the job gets its turn. Cancelling it while the job waits for its turn drops the job
+func (k *KeyedExecutor[K, T]) SubmitCtx(ctx context.Context, key K, fn func(context.Context) (T, error), opts ...SubmitOption) *Future[T] {
+	future := newFuture[T]()
+	turn := newFuture[struct{}]()
+
+	finish := func() {
+		turn.complete(&result.Result[struct{}]{})
+		k.lock.Lock()
+		if k.tails[key] == turn {
+			delete(k.tails, key)
+		}
+		k.lock.Unlock()
+	}
+	start := func() {
+		if future.result.Load() != nil {
+			// cancelled while waiting for its turn
+			finish()
+			return
+		}
+		opts := append(opts[:len(opts):len(opts)], withFuture(future), withOnFinish(finish))
+		if submitted := k.executor.SubmitCtx(ctx, fn, opts...); submitted != future {
+			// deduplicated into a job submitted before
+			submitted.OnComplete(func(r *result.Result[T]) { future.complete(r) })
+		}
+	}
+
+	k.lock.Lock()
+	previous := k.tails[key]
+	k.tails[key] = turn
+	k.lock.Unlock()
+
+	if previous == nil {
+		start()
+		return future
+	}
+	k.waiting.Add(1)
+	previous.OnComplete(func(*result.Result[struct{}]) {
+		k.waiting.Add(-1)
+		// submitting may block when the executor queue is full, which must not hold the goroutine finishing the
+		// previous job
+		go start()
+	})
+	return future
+}
+
+// withFuture makes the submission complete the given future instead of a new one
+func withFuture[T any](future *Future[T]) SubmitOption {
+	return func(o *submitOptions) {
+		o.future = future
+	}
+}
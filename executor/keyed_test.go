@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedExecutor(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeyed[string, int](3)
+
+	var lock sync.Mutex
+	order := map[string][]int{}
+	running := map[string]bool{}
+	var inFlight, maxInFlight atomic.Int64
+
+	futures := Futures[int]{}
+	for i := range 30 {
+		key := fmt.Sprintf("key-%d", i%5)
+		futures.Add(k.Submit(ctx, key, func() (int, error) {
+			lock.Lock()
+			require.False(t, running[key], "two jobs running for %s", key)
+			running[key] = true
+			order[key] = append(order[key], i)
+			lock.Unlock()
+
+			current := inFlight.Add(1)
+			for prev := maxInFlight.Load(); current > prev && !maxInFlight.CompareAndSwap(prev, current); {
+				prev = maxInFlight.Load()
+			}
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+
+			lock.Lock()
+			running[key] = false
+			lock.Unlock()
+			return i, nil
+		}))
+	}
+	require.NoError(t, CollectFutures(ctx, futures).Error())
+
+	// jobs with the same key ran in submission order, and jobs with different keys ran in parallel
+	for key, indexes := range order {
+		require.Len(t, indexes, 6, key)
+		for i := 1; i < len(indexes); i++ {
+			require.Less(t, indexes[i-1], indexes[i], key)
+		}
+	}
+	require.Greater(t, maxInFlight.Load(), int64(1))
+	require.LessOrEqual(t, maxInFlight.Load(), int64(3))
+	require.Equal(t, int64(0), k.Waiting())
+	require.Eventually(t, func() bool {
+		k.lock.Lock()
+		defer k.lock.Unlock()
+		return len(k.tails) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestKeyedExecutorCancel(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeyed[string, int](-1)
+
+	release := make(chan struct{})
+	first := k.Submit(ctx, "a", func() (int, error) { <-release; return 1, nil })
+	secondCalled := false
+	second := k.Submit(ctx, "a", func() (int, error) { secondCalled = true; return 2, nil })
+	third := k.Submit(ctx, "a", func() (int, error) { return 3, nil })
+	require.Equal(t, int64(2), k.Waiting())
+
+	// cancelling a waiting job does not let the ones after it skip the line
+	require.True(t, second.Cancel())
+	time.Sleep(5 * time.Millisecond)
+	require.False(t, third.IsDone())
+
+	close(release)
+	require.Equal(t, 1, first.Get(ctx).Must())
+	require.Equal(t, 1, first.Attempts())
+	require.Equal(t, 3, third.Get(ctx).Must())
+	require.ErrorIs(t, second.Get(ctx).Err, context.Canceled)
+	require.False(t, secondCalled)
+	require.Equal(t, int64(2), k.Executor().Submitted())
+
+	// cancelling a running job cancels its context
+	started := make(chan struct{})
+	running := k.SubmitCtx(ctx, "b", func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	next := k.Submit(ctx, "b", func() (int, error) { return 4, nil })
+	<-started
+	require.Equal(t, Executing, running.State())
+	require.Equal(t, 1, running.Attempts())
+	require.True(t, running.Cancel())
+	require.Equal(t, 4, next.Get(ctx).Must())
+	require.Equal(t, Cancelled, running.State())
+	require.Equal(t, int64(1), k.Executor().Cancelled())
+}
+
+func TestKeyedExecutorTimeout(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeyed[string, int](-1)
+
+	release := make(chan struct{})
+	var returned atomic.Bool
+	first := k.Submit(ctx, "a", func() (int, error) {
+		<-release
+		returned.Store(true)
+		return 1, nil
+	}, WithJobTimeout(10*time.Millisecond))
+	second := k.Submit(ctx, "a", func() (int, error) {
+		if !returned.Load() {
+			return 0, errors.New("overlapped with the previous job")
+		}
+		return 2, nil
+	})
+
+	// the first job timed out, but its turn lasts until its function returns
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, first.Get(ctx).Err, &timeoutErr)
+	time.Sleep(5 * time.Millisecond)
+	require.False(t, second.IsDone())
+	require.Equal(t, int64(1), k.Waiting())
+
+	close(release)
+	require.Equal(t, 2, second.Get(ctx).Must())
+	require.Equal(t, int64(0), k.Waiting())
+}
+
+func TestKeyedExecutorDedupe(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeyed[string, int](-1, WithTimeout[int](10*time.Millisecond))
+
+	release := make(chan struct{})
+	var returned atomic.Bool
+	shared := k.Executor().Submit(ctx, func() (int, error) {
+		<-release
+		returned.Store(true)
+		return 1, nil
+	}, WithDedupeKey("x"))
+	deduplicated := k.Submit(ctx, "d", func() (int, error) { return 2, nil }, WithDedupeKey("x"))
+	next := k.Submit(ctx, "d", func() (int, error) {
+		if !returned.Load() {
+			return 0, errors.New("overlapped with the previous job")
+		}
+		return 3, nil
+	})
+	require.Equal(t, int64(1), k.Executor().Deduplicated())
+
+	// the shared job timed out, but the deduplicated turn lasts until its function returns
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, deduplicated.Get(ctx).Err, &timeoutErr)
+	require.Equal(t, shared.Get(ctx), deduplicated.Get(ctx))
+	time.Sleep(5 * time.Millisecond)
+	require.False(t, next.IsDone())
+	require.Equal(t, int64(1), k.Waiting())
+
+	close(release)
+	require.Equal(t, 3, next.Get(ctx).Must())
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// limiter is a weighted semaphore that serves its waiters by priority, highest first, and in FIFO order among
+// waiters of the same priority.
+//
+// Like semaphore.Weighted, a waiter that does not fit blocks the ones behind it, so heavier waiters are not starved
+// by lighter ones
+type limiter struct {
+	lock    sync.Mutex
+	size    int64 // negative means unlimited
+	cur     int64
+	waiters waiters
+}
+
+type waiter struct {
+	weight   int64
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+func newLimiter(size int64) *limiter {
+	return &limiter{size: size}
+}
+
+// acquire blocks until the given weight is available or the context is done. seq breaks ties between waiters of the
+// same priority, lower values being served first.
+//
+// Returns the acquired weight, which must be passed back to release. It is lower than the requested weight when the
+// limiter was resized below it, in which case the waiter takes the whole limiter instead of blocking forever
+func (l *limiter) acquire(ctx context.Context, weight int64, priority int, seq uint64) (int64, error) {
+	l.lock.Lock()
+	if len(l.waiters) == 0 && l.fits(weight) {
+		weight = l.clamp(weight)
+		l.cur += weight
+		l.lock.Unlock()
+		return weight, nil
+	}
+	w := &waiter{weight: weight, priority: priority, seq: seq, ready: make(chan struct{})}
+	heap.Push(&l.waiters, w)
+	l.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.weight, nil
+	case <-ctx.Done():
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		select {
+		case <-w.ready:
+			// acquired right when the context got done. Give it back
+			l.cur -= w.weight
+		default:
+			heap.Remove(&l.waiters, w.index)
+		}
+		// the waiter leaving may allow the ones behind it to proceed
+		l.notify()
+		return 0, ctx.Err()
+	}
+}
+
+func (l *limiter) release(weight int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.cur -= weight
+	l.notify()
+}
+
+// resize changes the limiter size. Shrinking it does not affect current holders, but waiters only proceed once
+// holders are back under the new size
+func (l *limiter) resize(size int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.size = size
+	l.notify()
+}
+
+func (l *limiter) fits(weight int64) bool {
+	return l.size < 0 || l.size-l.cur >= l.clamp(weight)
+}
+
+func (l *limiter) clamp(weight int64) int64 {
+	if l.size >= 0 && weight > l.size {
+		return l.size
+	}
+	return weight
+}
+
+// notify wakes up waiters in order for as long as they fit
+func (l *limiter) notify() {
+	for len(l.waiters) > 0 {
+		w := l.waiters[0]
+		if !l.fits(w.weight) {
+			return
+		}
+		heap.Pop(&l.waiters)
+		w.weight = l.clamp(w.weight)
+		l.cur += w.weight
+		close(w.ready)
+	}
+}
+
+// waiters is a priority queue of waiters, implementing heap.Interface
+type waiters []*waiter
+
+func (ws waiters) Len() int {
+	return len(ws)
+}
+
+func (ws waiters) Less(i, j int) bool {
+	return before(ws[i].priority, ws[i].seq, ws[j].priority, ws[j].seq)
+}
+
+func (ws waiters) Swap(i, j int) {
+	ws[i], ws[j] = ws[j], ws[i]
+	ws[i].index = i
+	ws[j].index = j
+}
+
+func (ws *waiters) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*ws)
+	*ws = append(*ws, w)
+}
+
+func (ws *waiters) Pop() any {
+	old := *ws
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*ws = old[:len(old)-1]
+	return w
+}
+
+// before defines the scheduling order: higher priority first, then lower sequence number (FIFO)
+func before(priority1 int, seq1 uint64, priority2 int, seq2 uint64) bool {
+	if priority1 != priority2 {
+		return priority1 > priority2
+	}
+	return seq1 < seq2
+}
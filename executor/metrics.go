@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StatsSource is anything that exposes executor statistics, like any Executor regardless of its job type
+type StatsSource interface {
+	Stats() Stats
+}
+
+// Metrics renders the statistics of named executors in the Prometheus text exposition format, without depending on
+// the Prometheus client libraries. It is an http.Handler, so it can be served directly. Example:
+//
+//	metrics := NewMetrics()
+//	metrics.Register("fetch", fetchExecutor)
+//	metrics.Register("parse", parseExecutor)
+//	http.Handle("/metrics", metrics)
+//
+// Each executor is identified by an executor label holding its name
+type Metrics struct {
+	lock    sync.Mutex
+	sources map[string]StatsSource
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{sources: map[string]StatsSource{}}
+}
+
+// Register adds an executor to the metrics under the given name, replacing any executor registered with that name
+func (m *Metrics) Register(name string, source StatsSource) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sources[name] = source
+}
+
+// Unregister removes the executor registered with the given name, if any
+func (m *Metrics) Unregister(name string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.sources, name)
+}
+
+// ServeHTTP responds with the metrics of all registered executors
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.Write(w)
+}
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(Stats) float64
+}
+
+var metrics = []metric{
+	{"executor_submitted_total", "Jobs submitted to the executor.", "counter", func(s Stats) float64 { return float64(s.Submitted) }},
+	{"executor_launched_total", "Jobs that started waiting for their turn to run.", "counter", func(s Stats) float64 { return float64(s.Launched) }},
+	{"executor_done_total", "Jobs done, successfully or not.", "counter", func(s Stats) float64 { return float64(s.Done) }},
+	{"executor_failed_total", "Jobs done with an error.", "counter", func(s Stats) float64 { return float64(s.Failed) }},
+	{"executor_attempts_total", "Calls to job functions, including retries.", "counter", func(s Stats) float64 { return float64(s.Attempts) }},
+	{"executor_retries_total", "Calls to job functions retrying a failure.", "counter", func(s Stats) float64 { return float64(s.Retries) }},
+	{"executor_timed_out_total", "Jobs that did not finish within their timeout.", "counter", func(s Stats) float64 { return float64(s.TimedOut) }},
+	{"executor_cancelled_total", "Jobs whose futures were cancelled.", "counter", func(s Stats) float64 { return float64(s.Cancelled) }},
+	{"executor_deduplicated_total", "Submissions deduplicated into an existing job.", "counter", func(s Stats) float64 { return float64(s.Deduplicated) }},
+	{"executor_throttled_total", "Jobs that waited for the rate limit.", "counter", func(s Stats) float64 { return float64(s.Throttled) }},
+	{"executor_throttled_seconds_total", "Time jobs spent waiting for the rate limit.", "counter", func(s Stats) float64 { return s.ThrottledTime.Seconds() }},
+	{"executor_in_flight", "Jobs running.", "gauge", func(s Stats) float64 { return float64(s.InFlight) }},
+	{"executor_pending", "Jobs submitted and not done yet.", "gauge", func(s Stats) float64 { return float64(s.Pending) }},
+	{"executor_peak_in_flight", "Highest number of jobs that ran at the same time.", "gauge", func(s Stats) float64 { return float64(s.PeakInFlight) }},
+	{"executor_max_parallelism", "How many jobs can run at the same time.", "gauge", func(s Stats) float64 { return float64(s.MaxParallelism) }},
+}
+
+type histogramMetric struct {
+	name  string
+	help  string
+	value func(Stats) Histogram
+}
+
+var histogramMetrics = []histogramMetric{
+	{"executor_queue_wait_seconds", "Time jobs waited between being eligible to run and starting to run.", func(s Stats) Histogram { return s.QueueWaitHistogram }},
+	{"executor_run_seconds", "Time jobs took to run, including retries.", func(s Stats) Histogram { return s.RunTimeHistogram }},
+}
+
+// Write writes the metrics of all registered executors to w, sorted by executor name
+func (m *Metrics) Write(w io.Writer) error {
+	m.lock.Lock()
+	names := make([]string, 0, len(m.sources))
+	sources := make([]StatsSource, 0, len(m.sources))
+	for name := range m.sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		sources = append(sources, m.sources[name])
+	}
+	m.lock.Unlock()
+
+	// stats are taken once per executor, so that all its metrics come from the same snapshot
+	stats := make([]Stats, len(sources))
+	for i, source := range sources {
+		stats[i] = source.Stats()
+	}
+
+	out := bufio.NewWriter(w)
+	for _, metric := range metrics {
+		writeHeader(out, metric.name, metric.help, metric.kind)
+		for i, name := range names {
+			writeSample(out, metric.name, name, "", metric.value(stats[i]))
+		}
+	}
+	for _, metric := range histogramMetrics {
+		writeHeader(out, metric.name, metric.help, "histogram")
+		for i, name := range names {
+			histogram := metric.value(stats[i])
+			for _, bucket := range histogram.Buckets {
+				writeSample(out, metric.name+"_bucket", name, formatFloat(bucket.UpperBound), float64(bucket.Count))
+			}
+			writeSample(out, metric.name+"_bucket", name, "+Inf", float64(histogram.Count))
+			writeSample(out, metric.name+"_sum", name, "", histogram.Sum)
+			writeSample(out, metric.name+"_count", name, "", float64(histogram.Count))
+		}
+	}
+	return out.Flush()
+}
+
+func writeHeader(out *bufio.Writer, name string, help string, kind string) {
+	out.WriteString("# HELP " + name + " " + help + "\n")
+	out.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func writeSample(out *bufio.Writer, name string, executor string, le string, value float64) {
+	out.WriteString(name + `{executor="` + escapeLabel(executor) + `"`)
+	if le != "" {
+		out.WriteString(`,le="` + le + `"`)
+	}
+	out.WriteString("} " + formatFloat(value) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	ints := New[int](2)
+	require.NoError(t, ints.Submit(ctx, func() (int, error) { return 1, nil }).Get(ctx).Err)
+	require.Error(t, ints.Submit(ctx, func() (int, error) { return 0, errors.New("boom") }).Get(ctx).Err)
+	require.NoError(t, ints.WaitIdle(ctx))
+	strs := New[string](3)
+
+	metrics := NewMetrics()
+	metrics.Register("ints", ints)
+	metrics.Register(`quoted "strs"`, strs)
+	metrics.Register("removed", New[int](1))
+	metrics.Unregister("removed")
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		"# HELP executor_submitted_total Jobs submitted to the executor.\n" +
+			"# TYPE executor_submitted_total counter\n" +
+			`executor_submitted_total{executor="ints"} 2` + "\n" +
+			`executor_submitted_total{executor="quoted \"strs\""} 0` + "\n",
+		`executor_done_total{executor="ints"} 2` + "\n",
+		`executor_failed_total{executor="ints"} 1` + "\n",
+		"# TYPE executor_in_flight gauge\n",
+		`executor_max_parallelism{executor="quoted \"strs\""} 3` + "\n",
+		"# TYPE executor_run_seconds histogram\n",
+		`executor_run_seconds_bucket{executor="ints",le="0.001"} `,
+		`executor_run_seconds_bucket{executor="ints",le="60"} 2` + "\n" +
+			`executor_run_seconds_bucket{executor="ints",le="+Inf"} 2` + "\n" +
+			`executor_run_seconds_sum{executor="ints"} `,
+		`executor_run_seconds_count{executor="ints"} 2` + "\n",
+		`executor_queue_wait_seconds_count{executor="quoted \"strs\""} 0` + "\n",
+	} {
+		require.Contains(t, body, expected)
+	}
+	require.NotContains(t, body, "removed")
+
+	// every line is either a comment or a sample
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		require.Regexp(t, `^(# (HELP|TYPE) \w+ .+|\w+\{executor="(\\"|[^"])*"(,le="[^"]+")?\} \S+)$`, line)
+	}
+}
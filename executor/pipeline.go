@@ -0,0 +1,229 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bcap/go-lib/result"
+)
+
+// Pipeline runs items through a sequence of stages, each one backed by its own Executor, so every stage has its own
+// parallelism and its own bounded buffer of items waiting to be processed. Items move to the next stage as soon as
+// they are ready, without waiting for the rest of the items in the current stage.
+//
+// Pipelines are built with NewPipeline and AddStage, which keep the types of consecutive stages in sync. Example:
+//
+//	p := NewPipeline[string]()
+//	fetched := AddStage(p, "fetch", 8, 100, fetch)     // string -> []byte
+//	parsed := AddStage(fetched, "parse", 2, 10, parse) // []byte -> Document
+//	for doc, err := range parsed.Run(ctx, slices.Values(urls)) {
+//		...
+//	}
+type Pipeline[In, Out any] struct {
+	stages     []*stage
+	deadLetter func(DeadLetter)
+}
+
+// DeadLetter is an item that failed in a pipeline stage, handed to the sink set with WithDeadLetter
+type DeadLetter struct {
+	Stage string
+	Input any // the input of the failed stage
+	Err   error
+}
+
+// StageStats holds the counters of a pipeline stage. Most of them mirror the counters of the stage Executor
+type StageStats struct {
+	Name         string
+	Submitted    int64
+	Launched     int64
+	InFlight     int64
+	Done         int64
+	Pending      int64
+	Retries      int64
+	Failed       int64
+	DeadLettered int64
+}
+
+type stage struct {
+	name         string
+	executor     *Executor[any]
+	fn           func(context.Context, any) (any, error)
+	failed       atomic.Int64
+	deadLettered atomic.Int64
+}
+
+// NewPipeline creates a pipeline without stages, which outputs its inputs unchanged
+func NewPipeline[In any]() *Pipeline[In, In] {
+	return &Pipeline[In, In]{}
+}
+
+// AddStage returns a new pipeline with a stage appended, leaving p unchanged.
+//
+// The stage runs fn with at most maxParallelism items at a time. Up to bufferSize items wait for their turn, after
+// which the previous stage blocks until there is room. A bufferSize <= 0 means an unbounded buffer. As in New, a
+// maxParallelism of 0 means the number of CPUs, and a negative one means no limit, in which case items never wait
+// and bufferSize is ignored. Executor options like WithRetry or WithTimeout can be given to customize how the stage
+// runs its items
+func AddStage[In, Mid, Out any](p *Pipeline[In, Mid], name string, maxParallelism int, bufferSize int, fn func(context.Context, Mid) (Out, error), opts ...Option[any]) *Pipeline[In, Out] {
+	if maxParallelism >= 0 {
+		opts = append([]Option[any]{WithWorkers[any](bufferSize)}, opts...)
+	}
+	s := &stage{
+		name:     name,
+		executor: New(maxParallelism, opts...),
+		fn: func(ctx context.Context, input any) (any, error) {
+			mid, _ := input.(Mid)
+			return fn(ctx, mid)
+		},
+	}
+	return &Pipeline[In, Out]{
+		stages:     append(p.stages[:len(p.stages):len(p.stages)], s),
+		deadLetter: p.deadLetter,
+	}
+}
+
+// WithDeadLetter returns a new pipeline that hands items failing in any stage to sink, instead of yielding their
+// errors from Run. sink is called concurrently from the stages goroutines, so it should be quick and safe for
+// concurrent use
+func (p *Pipeline[In, Out]) WithDeadLetter(sink func(DeadLetter)) *Pipeline[In, Out] {
+	return &Pipeline[In, Out]{
+		stages:     p.stages,
+		deadLetter: sink,
+	}
+}
+
+// Stats returns the counters of each stage, in stage order
+func (p *Pipeline[In, Out]) Stats() []StageStats {
+	stats := make([]StageStats, len(p.stages))
+	for i, s := range p.stages {
+		stats[i] = StageStats{
+			Name:         s.name,
+			Submitted:    s.executor.Submitted(),
+			Launched:     s.executor.Launched(),
+			InFlight:     s.executor.InFlight(),
+			Done:         s.executor.Done(),
+			Pending:      s.executor.Pending(),
+			Retries:      s.executor.Retries(),
+			Failed:       s.failed.Load(),
+			DeadLettered: s.deadLettered.Load(),
+		}
+	}
+	return stats
+}
+
+// Run sends the inputs through the pipeline stages, yielding the outputs in the order they are ready.
+//
+// Items failing in a stage are yielded with an error identifying the stage, unless the pipeline has a dead-letter
+// sink. Stopping the iteration early stops reading inputs and cancels the items still flowing through the stages
+func (p *Pipeline[In, Out]) Run(ctx context.Context, inputs iter.Seq[In]) iter.Seq2[Out, error] {
+	return func(yield func(Out, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		r := &pipelineRun{
+			stages:     p.stages,
+			deadLetter: p.deadLetter,
+			links:      make([]chan any, len(p.stages)),
+			outputs:    make(chan pipelineOutput),
+		}
+		for i := range r.links {
+			r.links[i] = make(chan any)
+			go r.forward(ctx, i)
+		}
+		go feed(ctx, r, inputs)
+
+		for output := range r.outputs {
+			r.items.Done()
+			value, _ := output.value.(Out)
+			if !yield(value, output.err) {
+				return
+			}
+		}
+	}
+}
+
+type pipelineOutput struct {
+	value any
+	err   error
+}
+
+// pipelineRun is the state of a single Pipeline.Run call
+type pipelineRun struct {
+	stages     []*stage
+	deadLetter func(DeadLetter)
+	links      []chan any // outputs of each stage, in completion order
+	outputs    chan pipelineOutput
+	items      sync.WaitGroup // items still flowing through the pipeline
+}
+
+// feed sends the inputs to the first stage, closing the outputs once all items went through the pipeline
+func feed[In any](ctx context.Context, r *pipelineRun, inputs iter.Seq[In]) {
+	for input := range inputs {
+		if ctx.Err() != nil {
+			break
+		}
+		r.items.Add(1)
+		r.send(ctx, 0, input)
+	}
+	r.items.Wait()
+	close(r.outputs)
+}
+
+// forward moves the outputs of stage i to the next one, until the run is over
+func (r *pipelineRun) forward(ctx context.Context, i int) {
+	for {
+		select {
+		case value := <-r.links[i]:
+			r.send(ctx, i+1, value)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send submits an item to stage i, or to the run outputs after the last stage. Blocks while the stage buffer is
+// full, which propagates backpressure to the previous stages
+func (r *pipelineRun) send(ctx context.Context, i int, value any) {
+	if i == len(r.stages) {
+		r.output(ctx, pipelineOutput{value: value})
+		return
+	}
+	s := r.stages[i]
+	future := s.executor.SubmitCtx(ctx, func(ctx context.Context) (any, error) { return s.fn(ctx, value) })
+	future.OnComplete(func(res *result.Result[any]) {
+		switch {
+		case ctx.Err() != nil:
+			// the run is over, the item is dropped
+			r.items.Done()
+		case res.Err != nil:
+			r.fail(ctx, s, value, res.Err)
+		default:
+			select {
+			case r.links[i] <- res.Value:
+			case <-ctx.Done():
+				r.items.Done()
+			}
+		}
+	})
+}
+
+func (r *pipelineRun) fail(ctx context.Context, s *stage, input any, err error) {
+	s.failed.Add(1)
+	if r.deadLetter == nil {
+		r.output(ctx, pipelineOutput{err: fmt.Errorf("stage %s: %w", s.name, err)})
+		return
+	}
+	r.deadLetter(DeadLetter{Stage: s.name, Input: input, Err: err})
+	s.deadLettered.Add(1)
+	r.items.Done()
+}
+
+func (r *pipelineRun) output(ctx context.Context, output pipelineOutput) {
+	select {
+	case r.outputs <- output:
+	case <-ctx.Done():
+		r.items.Done()
+	}
+}
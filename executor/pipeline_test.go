@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	var inFlight, maxInFlight atomic.Int64
+	parsed := AddStage(NewPipeline[string](), "parse", 2, 2, func(_ context.Context, s string) (int, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for prev := maxInFlight.Load(); current > prev && !maxInFlight.CompareAndSwap(prev, current); {
+			prev = maxInFlight.Load()
+		}
+		time.Sleep(time.Millisecond)
+		return strconv.Atoi(s)
+	})
+	// a negative maxParallelism means no limit, as in New
+	doubled := AddStage(parsed, "double", -1, 0, func(_ context.Context, v int) (int, error) {
+		return v * 2, nil
+	})
+	formatted := AddStage(doubled, "format", 1, 1, func(_ context.Context, v int) (string, error) {
+		return "#" + strconv.Itoa(v), nil
+	})
+
+	inputs := []string{"1", "2", "x", "3", "4", "5"}
+	outputs := []string{}
+	var errs []error
+	for output, err := range formatted.Run(ctx, slices.Values(inputs)) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		outputs = append(outputs, output)
+	}
+	require.ElementsMatch(t, []string{"#2", "#4", "#6", "#8", "#10"}, outputs)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "stage parse:")
+	require.ErrorIs(t, errs[0], strconv.ErrSyntax)
+	require.LessOrEqual(t, maxInFlight.Load(), int64(2))
+
+	// the stage executors account for an item right after handing it to the next stage
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		require.Equal(c, []StageStats{
+			{Name: "parse", Submitted: 6, Launched: 6, Done: 6, Failed: 1},
+			{Name: "double", Submitted: 5, Launched: 5, Done: 5},
+			{Name: "format", Submitted: 5, Launched: 5, Done: 5},
+		}, formatted.Stats())
+	}, time.Second, time.Millisecond)
+
+	// the pipelines used to build formatted are not changed by adding stages
+	require.Len(t, parsed.Stats(), 1)
+	values := []int{}
+	for v, err := range NewPipeline[int]().Run(ctx, slices.Values([]int{1, 2})) {
+		require.NoError(t, err)
+		values = append(values, v)
+	}
+	require.Equal(t, []int{1, 2}, values)
+}
+
+func TestPipelineDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	errOdd := errors.New("odd")
+
+	var lock sync.Mutex
+	deadLetters := []DeadLetter{}
+	p := AddStage(NewPipeline[int](), "even", 2, 2, func(_ context.Context, v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v, nil
+	}).WithDeadLetter(func(d DeadLetter) {
+		lock.Lock()
+		defer lock.Unlock()
+		deadLetters = append(deadLetters, d)
+	})
+
+	outputs := []int{}
+	for v, err := range p.Run(ctx, slices.Values([]int{1, 2, 3, 4})) {
+		require.NoError(t, err)
+		outputs = append(outputs, v)
+	}
+	require.ElementsMatch(t, []int{2, 4}, outputs)
+	require.ElementsMatch(t, []DeadLetter{{Stage: "even", Input: 1, Err: errOdd}, {Stage: "even", Input: 3, Err: errOdd}}, deadLetters)
+	require.Equal(t, int64(2), p.Stats()[0].DeadLettered)
+}
+
+func TestPipelineStopEarly(t *testing.T) {
+	ctx := context.Background()
+
+	var read atomic.Int64
+	naturals := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			read.Add(1)
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	p := AddStage(NewPipeline[int](), "slow", 2, 2, func(ctx context.Context, v int) (int, error) {
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+		}
+		return v, ctx.Err()
+	})
+	p = AddStage(p, "identity", 1, 1, func(_ context.Context, v int) (int, error) { return v, nil })
+
+	yielded := 0
+	for _, err := range p.Run(ctx, naturals) {
+		require.NoError(t, err)
+		yielded++
+		if yielded == 10 {
+			break
+		}
+	}
+
+	// buffers bound how many inputs were read ahead, and the stages drain once the run stops
+	require.Less(t, read.Load(), int64(30))
+	require.Eventually(t, func() bool {
+		for _, stats := range p.Stats() {
+			if stats.Pending > 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+}
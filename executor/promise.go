@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bcap/go-lib/result"
+)
+
+// ErrChanClosed is the error set on futures returned by FromChan when the channel is closed without a value
+var ErrChanClosed = errors.New("channel closed without a value")
+
+// Promise is a future that is completed manually instead of by an Executor. It is meant to bridge callback based
+// code into Futures, so it can be combined and collected like any job. Example:
+//
+//	p := NewPromise[string]()
+//	client.Fetch(url, func(body string, err error) {
+//		if err != nil {
+//			p.Reject(err)
+//			return
+//		}
+//		p.Resolve(body)
+//	})
+//	body := p.Future().Get(ctx)
+type Promise[T any] struct {
+	future *Future[T]
+}
+
+func NewPromise[T any]() *Promise[T] {
+	return &Promise[T]{future: newFuture[T]()}
+}
+
+// Future returns the future completed by the promise
+func (p *Promise[T]) Future() *Future[T] {
+	return p.future
+}
+
+// Resolve completes the promise future successfully with value.
+// Only the first completion has any effect, later ones return false
+func (p *Promise[T]) Resolve(value T) bool {
+	return p.future.complete(&result.Result[T]{Value: value})
+}
+
+// Reject completes the promise future with err.
+// Only the first completion has any effect, later ones return false
+func (p *Promise[T]) Reject(err error) bool {
+	return p.future.complete(&result.Result[T]{Err: err})
+}
+
+// FromChan returns a future that completes with the first value received from ch. If ch is closed without a value
+// the future fails with ErrChanClosed, and if ctx is done first it fails with the context error
+func FromChan[T any](ctx context.Context, ch <-chan T) *Future[T] {
+	p := NewPromise[T]()
+	go func() {
+		select {
+		case value, ok := <-ch:
+			if !ok {
+				p.Reject(ErrChanClosed)
+				return
+			}
+			p.Resolve(value)
+		case <-ctx.Done():
+			p.Reject(ctx.Err())
+		}
+	}()
+	return p.Future()
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bcap/go-lib/result"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromise(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	p := NewPromise[int]()
+	require.Equal(t, AwaitingExecution, p.Future().State())
+	_, ok := p.Future().GetNoBlock()
+	require.False(t, ok)
+	go p.Resolve(1)
+	require.Equal(t, 1, p.Future().Get(ctx).Must())
+	require.False(t, p.Reject(errBoom))
+	require.Equal(t, 1, p.Future().Get(ctx).Must())
+
+	// promises mix with executor futures
+	e := New[int](1)
+	rejected := NewPromise[int]()
+	futures := Futures[int]{}
+	futures.Submit(ctx, e, func() (int, error) { return 2, nil })
+	futures.Add(p.Future())
+	futures.Add(rejected.Future())
+	require.True(t, rejected.Reject(errBoom))
+	require.Equal(t, []int{2, 1}, AllOf(futures[:2]).Get(ctx).Must())
+	var resultsErr *result.ResultsError
+	require.ErrorAs(t, CollectFutures(ctx, futures).Error(), &resultsErr)
+	require.Equal(t, []error{errBoom}, resultsErr.Errors)
+
+	// cancelling a promise future wins over later completions
+	cancelled := NewPromise[int]()
+	require.True(t, cancelled.Future().Cancel())
+	require.False(t, cancelled.Resolve(1))
+	require.Equal(t, Cancelled, cancelled.Future().State())
+}
+
+func TestFromChan(t *testing.T) {
+	ctx := context.Background()
+
+	ch := make(chan int, 1)
+	ch <- 1
+	require.Equal(t, 1, FromChan(ctx, ch).Get(ctx).Must())
+
+	close(ch)
+	require.ErrorIs(t, FromChan(ctx, ch).Get(ctx).Err, ErrChanClosed)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, FromChan(timeoutCtx, make(chan int)).Get(ctx).Err, context.DeadlineExceeded)
+}
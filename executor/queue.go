@@ -0,0 +1,189 @@
+package executor
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+type jobState int32
+
+const (
+	jobQueued  jobState = 0
+	jobStarted jobState = 1
+	jobDropped jobState = 2
+)
+
+// job is a unit of work submitted to an Executor
+type job[T any] struct {
+	ctx        context.Context // derived from the submission context, also cancelled by Executor.ShutdownNow
+	cancel     func()
+	fn         func(context.Context) (T, error)
+	future     *Future[T]
+	retry      RetryPolicy
+	timeout    time.Duration
+	priority   int
+	weight     int64
+	acquired   int64 // parallelism slots taken while running, which can be less than weight. See limiter.acquire
+	seq        uint64
+	index      int // position in the queue heap, -1 when not queued. Guarded by the queue lock
+	state      atomic.Int32
+	dispatched time.Time // when the job became eligible to run
+
+	finishLock  sync.Mutex
+	hasFinished bool
+	onFinish    []func()
+}
+
+// transition moves a queued job to the given state. Only one transition out of jobQueued can succeed, which is
+// what decides who owns completing the job future
+func (j *job[T]) transition(state jobState) bool {
+	return j.state.CompareAndSwap(int32(jobQueued), int32(state))
+}
+
+// addOnFinish registers a function called once the job is done with. If it already is, the function is called right
+// away
+func (j *job[T]) addOnFinish(onFinish func()) {
+	j.finishLock.Lock()
+	if j.hasFinished {
+		j.finishLock.Unlock()
+		onFinish()
+		return
+	}
+	j.onFinish = append(j.onFinish, onFinish)
+	j.finishLock.Unlock()
+}
+
+// finished runs the job onFinish hooks. Called once the job is done with, which for a running job is only after its
+// function returned, even when its future completed earlier because of a timeout or a cancellation
+func (j *job[T]) finished() {
+	j.finishLock.Lock()
+	j.hasFinished = true
+	onFinish := j.onFinish
+	j.onFinish = nil
+	j.finishLock.Unlock()
+	for _, fn := range onFinish {
+		fn()
+	}
+}
+
+// queue holds jobs waiting to be picked up by the executor workers
+type queue[T any] struct {
+	lock     sync.Mutex
+	jobs     jobHeap[T]
+	capacity *semaphore.Weighted // nil when the queue is unbounded
+	workers  int
+}
+
+func newQueue[T any](size int) *queue[T] {
+	q := &queue[T]{}
+	if size > 0 {
+		q.capacity = semaphore.NewWeighted(int64(size))
+	}
+	return q
+}
+
+// push adds a job to the queue, blocking while the queue is full.
+// Returns true when a new worker should be started to consume the queue, which happens when there are less than
+// maxWorkers workers running
+func (q *queue[T]) push(ctx context.Context, j *job[T], maxWorkers int) (bool, error) {
+	if q.capacity != nil {
+		if err := q.capacity.Acquire(ctx, 1); err != nil {
+			return false, err
+		}
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	// the job may have been dropped while waiting for room, after remove had nothing to remove
+	if jobState(j.state.Load()) != jobQueued {
+		q.release()
+		return false, nil
+	}
+	heap.Push(&q.jobs, j)
+	if q.workers < maxWorkers {
+		q.workers++
+		return true, nil
+	}
+	return false, nil
+}
+
+// pop removes the next job from the queue: the one with the highest priority, and among those the oldest one.
+// When the queue is empty, or there are more than maxWorkers workers running, the calling worker is retired and nil
+// is returned
+func (q *queue[T]) pop(maxWorkers int) *job[T] {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.jobs) == 0 || q.workers > maxWorkers {
+		q.workers--
+		return nil
+	}
+	j := heap.Pop(&q.jobs).(*job[T])
+	q.release()
+	return j
+}
+
+// remove takes a dropped job out of the queue, freeing its room for other jobs. Does nothing if the job is not queued
+func (q *queue[T]) remove(j *job[T]) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if j.index < 0 {
+		return
+	}
+	heap.Remove(&q.jobs, j.index)
+	q.release()
+}
+
+func (q *queue[T]) release() {
+	if q.capacity != nil {
+		q.capacity.Release(1)
+	}
+}
+
+// grow reserves workers for the queued jobs after maxWorkers increased, returning how many new workers should be
+// started
+func (q *queue[T]) grow(maxWorkers int) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	start := min(len(q.jobs), maxWorkers) - q.workers
+	if start <= 0 {
+		return 0
+	}
+	q.workers += start
+	return start
+}
+
+// jobHeap is a priority queue of jobs, implementing heap.Interface
+type jobHeap[T any] []*job[T]
+
+func (h jobHeap[T]) Len() int {
+	return len(h)
+}
+
+func (h jobHeap[T]) Less(i, j int) bool {
+	return before(h[i].priority, h[i].seq, h[j].priority, h[j].seq)
+}
+
+func (h jobHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap[T]) Push(x any) {
+	j := x.(*job[T])
+	j.index = len(*h)
+	*h = append(*h, j)
+}
+
+func (h *jobHeap[T]) Pop() any {
+	old := *h
+	j := old[len(old)-1]
+	j.index = -1
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return j
+}
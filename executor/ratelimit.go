@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that allows rate events per second, with bursts of up to burst events
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait takes a token from the bucket, waiting until one is available or the context is done.
+// Returns for how long it waited
+func (r *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	r.lock.Lock()
+	now := time.Now()
+	r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	r.last = now
+	// tokens can go negative, which reserves the next tokens for the waiters in the order they arrived
+	r.tokens--
+	delay := time.Duration(-r.tokens / r.rate * float64(time.Second))
+	r.lock.Unlock()
+
+	if delay <= 0 {
+		return 0, nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		// give the reserved token back, as it will not be used
+		r.lock.Lock()
+		r.tokens++
+		r.lock.Unlock()
+		return time.Since(now), err
+	}
+	return delay, nil
+}
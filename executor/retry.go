@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy defines how jobs that return an error are retried. The zero value does not retry
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the job function is called, including the first call.
+	// Values <= 1 disable retries
+	MaxAttempts int
+	// Backoff computes how long to wait before each retry. Retries happen immediately when nil
+	Backoff Backoff
+	// Retryable decides if an error should be retried. All errors are retried when nil
+	Retryable func(error) bool
+}
+
+func (p RetryPolicy) shouldRetry(ctx context.Context, attempt int, err error) bool {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// Backoff computes how long to wait before a retry. retry is 1 for the first retry, and previous is the delay
+// returned for the previous retry (0 on the first retry)
+type Backoff func(retry int, previous time.Duration) time.Duration
+
+// ConstantBackoff waits the same delay before every retry
+func ConstantBackoff(delay time.Duration) Backoff {
+	return func(int, time.Duration) time.Duration {
+		return delay
+	}
+}
+
+// ExponentialBackoff doubles the delay on every retry, starting at base and capped at maxDelay.
+// A maxDelay <= 0 means no cap
+func ExponentialBackoff(base time.Duration, maxDelay time.Duration) Backoff {
+	return func(retry int, _ time.Duration) time.Duration {
+		delay := base
+		for i := 1; i < retry && (maxDelay <= 0 || delay < maxDelay); i++ {
+			if delay > math.MaxInt64/2 {
+				delay = math.MaxInt64
+				break
+			}
+			delay *= 2
+		}
+		if maxDelay > 0 && delay > maxDelay {
+			return maxDelay
+		}
+		return delay
+	}
+}
+
+// DecorrelatedJitterBackoff picks a random delay between base and 3 times the previous delay, capped at maxDelay.
+// A maxDelay <= 0 means no cap. This spreads the retries of concurrent jobs over time, avoiding retry storms. See
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func DecorrelatedJitterBackoff(base time.Duration, maxDelay time.Duration) Backoff {
+	return func(_ int, previous time.Duration) time.Duration {
+		if previous < base {
+			previous = base
+		}
+		upper := time.Duration(math.MaxInt64)
+		if previous <= math.MaxInt64/3 {
+			upper = previous * 3
+		}
+		if maxDelay > 0 && upper > maxDelay {
+			upper = maxDelay
+		}
+		if upper <= base {
+			return upper
+		}
+		return base + rand.N(upper-base)
+	}
+}
+
+// sleep waits for the given duration or until the context is done, whatever happens first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+
+	e := New(2, WithRetry[int](RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     ConstantBackoff(time.Millisecond),
+		Retryable:   func(err error) bool { return errors.Is(err, errTransient) },
+	}))
+
+	failing := func(failures int, err error) func() (int, error) {
+		calls := 0
+		return func() (int, error) {
+			calls++
+			if calls <= failures {
+				return 0, err
+			}
+			return calls, nil
+		}
+	}
+
+	// succeeds on the last allowed attempt
+	f := e.Submit(context.Background(), failing(2, errTransient))
+	require.Equal(t, 3, f.Get(context.Background()).Must())
+	require.Equal(t, 3, f.Attempts())
+
+	// gives up after the max attempts
+	f = e.Submit(context.Background(), failing(3, errTransient))
+	require.ErrorIs(t, f.Get(context.Background()).Err, errTransient)
+	require.Equal(t, 3, f.Attempts())
+
+	// non retryable errors are not retried
+	f = e.Submit(context.Background(), failing(1, errPermanent))
+	require.ErrorIs(t, f.Get(context.Background()).Err, errPermanent)
+	require.Equal(t, 1, f.Attempts())
+
+	// the executor policy can be overridden per submission
+	f = e.Submit(context.Background(), failing(4, errPermanent), WithJobRetry(RetryPolicy{MaxAttempts: 5}))
+	require.Equal(t, 5, f.Get(context.Background()).Must())
+	require.Equal(t, 5, f.Attempts())
+
+	require.Equal(t, int64(4), e.Submitted())
+	require.Equal(t, int64(3+3+1+5), e.Attempts())
+	require.Equal(t, int64(2+2+0+4), e.Retries())
+
+	// retries stop when the job context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	f = e.SubmitCtx(ctx, func(context.Context) (int, error) {
+		cancel()
+		return 0, errTransient
+	}, WithJobRetry(RetryPolicy{MaxAttempts: 10, Backoff: ConstantBackoff(time.Hour)}))
+	require.ErrorIs(t, f.Get(context.Background()).Err, errTransient)
+	require.Equal(t, 1, f.Attempts())
+}
+
+func TestBackoff(t *testing.T) {
+	constant := ConstantBackoff(time.Second)
+	require.Equal(t, time.Second, constant(1, 0))
+	require.Equal(t, time.Second, constant(10, time.Second))
+
+	exponential := ExponentialBackoff(time.Second, 10*time.Second)
+	require.Equal(t, 1*time.Second, exponential(1, 0))
+	require.Equal(t, 2*time.Second, exponential(2, 0))
+	require.Equal(t, 4*time.Second, exponential(3, 0))
+	require.Equal(t, 8*time.Second, exponential(4, 0))
+	require.Equal(t, 10*time.Second, exponential(5, 0))
+	require.Equal(t, 10*time.Second, exponential(1000, 0))
+
+	uncapped := ExponentialBackoff(time.Second, 0)
+	require.Equal(t, 1024*time.Second, uncapped(11, 0))
+	require.Equal(t, time.Duration(1<<63-1), uncapped(1000, 0))
+
+	jitter := DecorrelatedJitterBackoff(time.Second, 10*time.Second)
+	var delay time.Duration
+	for retry := 1; retry < 100; retry++ {
+		previous := delay
+		delay = jitter(retry, previous)
+		require.GreaterOrEqual(t, delay, time.Second)
+		require.LessOrEqual(t, delay, 10*time.Second)
+		require.LessOrEqual(t, delay, 3*max(previous, time.Second))
+	}
+}
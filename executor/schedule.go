@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SubmitAfter is the same as SubmitCtx, but the job only becomes eligible to run once the delay elapses.
+//
+// The job counts as submitted and pending right away: Shutdown waits for it, and ShutdownNow and Future.Cancel drop
+// it. If ctx is done while the job waits for its delay, the job fails with the context error right away
+func (e *Executor[T]) SubmitAfter(ctx context.Context, delay time.Duration, fn func(context.Context) (T, error), opts ...SubmitOption) *Future[T] {
+	return e.SubmitCtx(ctx, fn, append(opts[:len(opts):len(opts)], withDelay(delay))...)
+}
+
+// SubmitAt is the same as SubmitAfter, but the job becomes eligible to run at the given time. Times in the past make
+// the job eligible right away
+func (e *Executor[T]) SubmitAt(ctx context.Context, at time.Time, fn func(context.Context) (T, error), opts ...SubmitOption) *Future[T] {
+	return e.SubmitAfter(ctx, at.Sub(e.clock.Now()), fn, opts...)
+}
+
+func withDelay(delay time.Duration) SubmitOption {
+	return func(o *submitOptions) {
+		o.delay = delay
+	}
+}
+
+// schedule dispatches the job once the delay elapses, or as soon as its context is done
+func (e *Executor[T]) schedule(j *job[T], delay time.Duration) {
+	var dispatched atomic.Bool
+	dispatch := func() {
+		// jobs dropped while waiting are already finished and must not be dispatched
+		if dispatched.CompareAndSwap(false, true) && jobState(j.state.Load()) == jobQueued {
+			e.dispatch(j)
+		}
+	}
+	timer := e.clock.AfterFunc(delay, dispatch)
+	context.AfterFunc(j.ctx, func() {
+		timer.Stop()
+		dispatch()
+	})
+}
+
+// Periodic is a job submitted with SubmitEvery
+type Periodic[T any] struct {
+	executor *Executor[T]
+	ctx      context.Context
+	interval time.Duration
+	fn       func(context.Context) (T, error)
+	opts     []SubmitOption
+	runs     atomic.Int64
+
+	lock    sync.Mutex
+	timer   Timer
+	last    *Future[T]
+	stopped bool
+}
+
+// SubmitEvery submits fn every interval, starting one interval from now, until the returned Periodic is stopped,
+// ctx is done or the executor is shut down.
+//
+// Runs never overlap: when a run takes longer than the interval, the next one is submitted as soon as it completes.
+// Each run is a regular job, so it honors the executor parallelism and the given options. Panics if interval is not
+// positive
+func (e *Executor[T]) SubmitEvery(ctx context.Context, interval time.Duration, fn func(context.Context) (T, error), opts ...SubmitOption) *Periodic[T] {
+	if interval <= 0 {
+		panic("interval must be positive")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p := &Periodic[T]{
+		executor: e,
+		ctx:      ctx,
+		interval: interval,
+		fn:       fn,
+		opts:     opts,
+	}
+	p.schedule(interval)
+	context.AfterFunc(ctx, func() { p.Stop() })
+	return p
+}
+
+// Stop stops submitting new runs. A run already submitted is not affected, use Last to cancel it if needed.
+// Returns false if the Periodic was already stopped
+func (p *Periodic[T]) Stop() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped {
+		return false
+	}
+	p.stopped = true
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	return true
+}
+
+// Runs returns how many runs were submitted so far
+func (p *Periodic[T]) Runs() int64 {
+	return p.runs.Load()
+}
+
+// Last returns the future of the latest run, or nil if there was no run yet
+func (p *Periodic[T]) Last() *Future[T] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.last
+}
+
+func (p *Periodic[T]) schedule(delay time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped {
+		return
+	}
+	p.timer = p.executor.clock.AfterFunc(delay, p.run)
+}
+
+func (p *Periodic[T]) run() {
+	p.lock.Lock()
+	stopped := p.stopped
+	p.lock.Unlock()
+	if stopped {
+		return
+	}
+
+	// the next run is scheduled once this one finishes rather than once its future completes, as the future of a run
+	// that times out or is cancelled completes while its function may still be running
+	start := p.executor.clock.Now()
+	next := func() {
+		if p.executor.IsShutdown() {
+			p.Stop()
+			return
+		}
+		p.schedule(max(start.Add(p.interval).Sub(p.executor.clock.Now()), 0))
+	}
+
+	// submitting may block when the executor queue is full, so it must not hold the lock
+	future := p.executor.SubmitCtx(p.ctx, p.fn, append(p.opts[:len(p.opts):len(p.opts)], withOnFinish(next))...)
+	p.runs.Add(1)
+	p.lock.Lock()
+	p.last = future
+	p.lock.Unlock()
+}
@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock whose time only moves with Advance, which synchronously fires the timers that become due
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+	done  bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	if d <= 0 {
+		t.done = true
+		go f()
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	due := []*fakeTimer{}
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case t.done:
+		case !t.at.After(c.now):
+			t.done = true
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.lock.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// Timers returns how many timers are waiting to fire
+func (c *fakeClock) Timers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	count := 0
+	for _, t := range c.timers {
+		if !t.done {
+			count++
+		}
+	}
+	return count
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	return true
+}
+
+func TestExecutorSubmitAfter(t *testing.T) {
+	ctx := context.Background()
+	value := func(v int) func(context.Context) (int, error) {
+		return func(context.Context) (int, error) { return v, nil }
+	}
+
+	clock := newFakeClock()
+	e := New(1, WithClock[int](clock))
+	later := e.SubmitAfter(ctx, time.Minute, value(1))
+	at := e.SubmitAt(ctx, clock.Now().Add(2*time.Minute), value(2))
+	require.Equal(t, int64(2), e.Pending())
+	require.Equal(t, 3, e.SubmitAt(ctx, clock.Now().Add(-time.Minute), value(3)).Get(ctx).Must())
+
+	clock.Advance(59 * time.Second)
+	time.Sleep(5 * time.Millisecond)
+	require.False(t, later.IsDone())
+	require.Equal(t, int64(1), e.Launched())
+
+	clock.Advance(time.Second)
+	require.Equal(t, 1, later.Get(ctx).Must())
+	require.False(t, at.IsDone())
+	clock.Advance(time.Minute)
+	require.Equal(t, 2, at.Get(ctx).Must())
+
+	// cancelled jobs are not dispatched once their time comes
+	cancelled := e.SubmitAfter(ctx, time.Minute, value(4))
+	require.True(t, cancelled.Cancel())
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool { return e.Pending() == 0 }, time.Second, time.Millisecond)
+	require.Equal(t, int64(3), e.Launched())
+
+	// jobs whose context is done fail right away, without waiting for their time
+	cancelCtx, cancel := context.WithCancel(ctx)
+	waiting := e.SubmitAfter(cancelCtx, time.Hour, value(5))
+	cancel()
+	require.ErrorIs(t, waiting.Get(ctx).Err, context.Canceled)
+
+	// ShutdownNow drops jobs waiting for their time
+	e.SubmitAfter(ctx, time.Hour, value(6))
+	require.Len(t, e.ShutdownNow(), 1)
+	require.NoError(t, e.Shutdown(ctx))
+}
+
+func TestExecutorSubmitEvery(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	e := New(1, WithClock[int](clock))
+
+	var calls atomic.Int64
+	release := make(chan struct{}, 10)
+	p := e.SubmitEvery(ctx, 10*time.Second, func(context.Context) (int, error) {
+		<-release
+		return int(calls.Add(1)), nil
+	})
+	require.Nil(t, p.Last())
+
+	// advance must only happen once the next run is scheduled
+	tick := func(d time.Duration) {
+		require.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+		clock.Advance(d)
+	}
+
+	tick(10 * time.Second)
+	release <- struct{}{}
+	require.Equal(t, 1, p.Last().Get(ctx).Must())
+
+	tick(10 * time.Second)
+	// a run taking longer than the interval makes the next one start as soon as it completes
+	clock.Advance(15 * time.Second)
+	release <- struct{}{}
+	require.Eventually(t, func() bool { return p.Runs() == 3 }, time.Second, time.Millisecond)
+	release <- struct{}{}
+	require.Equal(t, 3, p.Last().Get(ctx).Must())
+
+	tick(5 * time.Second)
+	require.Equal(t, int64(3), p.Runs())
+	tick(5 * time.Second)
+	require.Equal(t, int64(4), p.Runs())
+	require.True(t, p.Stop())
+	require.False(t, p.Stop())
+	release <- struct{}{}
+	require.Equal(t, 4, p.Last().Get(ctx).Must())
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, 0, clock.Timers())
+
+	// shutting down the executor stops periodic jobs
+	p = e.SubmitEvery(ctx, time.Second, func(context.Context) (int, error) { return 0, nil })
+	require.NoError(t, e.Shutdown(ctx))
+	tick(time.Second)
+	require.ErrorIs(t, p.Last().Get(ctx).Err, ErrShutdown)
+	require.False(t, p.Stop())
+}
+
+func TestExecutorSubmitEveryTimeout(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	e := New(-1, WithClock[int](clock))
+
+	var running, maxRunning atomic.Int64
+	release := make(chan struct{})
+	p := e.SubmitEvery(ctx, 10*time.Second, func(context.Context) (int, error) {
+		current := running.Add(1)
+		for prev := maxRunning.Load(); current > prev && !maxRunning.CompareAndSwap(prev, current); {
+			prev = maxRunning.Load()
+		}
+		defer running.Add(-1)
+		<-release // ignores its context
+		return 0, nil
+	}, WithJobTimeout(10*time.Millisecond))
+
+	require.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(10 * time.Second)
+
+	// the run times out while its function keeps going, so the next run is not scheduled yet
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, p.Last().Get(ctx).Err, &timeoutErr)
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, 0, clock.Timers())
+	clock.Advance(30 * time.Second)
+	require.Equal(t, int64(1), p.Runs())
+
+	// once the function returns the next run is overdue, so it is submitted right away
+	close(release)
+	require.Eventually(t, func() bool { return p.Runs() == 2 }, time.Second, time.Millisecond)
+	require.True(t, p.Stop())
+	require.Equal(t, int64(1), maxRunning.Load())
+}
+
+func TestExecutorSubmitEveryPanicsOnBadInterval(t *testing.T) {
+	e := New[int](1)
+	fn := func(context.Context) (int, error) { return 0, nil }
+	require.PanicsWithValue(t, "interval must be positive", func() { e.SubmitEvery(context.Background(), 0, fn) })
+	require.PanicsWithValue(t, "interval must be positive", func() { e.SubmitEvery(context.Background(), -time.Second, fn) })
+	require.Equal(t, int64(0), e.Submitted())
+}
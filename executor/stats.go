@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bcap/go-lib/numstat"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms buckets
+var latencyBuckets = [...]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// statsWindow is how many of the latest jobs are sampled for the queue wait and run time distributions
+const statsWindow = 1024
+
+// Stats is a consistent snapshot of the executor counters, along with latency distributions of its latest jobs
+type Stats struct {
+	MaxParallelism int
+
+	Submitted    int64
+	Launched     int64
+	InFlight     int64
+	Done         int64
+	Pending      int64
+	Failed       int64 // jobs done with an error, including timeouts, cancellations and panics
+	Attempts     int64
+	Retries      int64
+	TimedOut     int64
+	Cancelled    int64
+	Deduplicated int64
+
+	Throttled     int64
+	ThrottledTime time.Duration
+
+	// PeakInFlight is the highest number of jobs that ran at the same time
+	PeakInFlight int64
+
+	// Uptime is the time since the executor was created, and Throughput the average done jobs per second over it
+	Uptime     time.Duration
+	Throughput float64
+
+	// QueueWait is the distribution, in seconds, of how long the latest jobs waited between being eligible to run
+	// and starting to run. RunTime is the distribution, in seconds, of how long they ran, including retries.
+	// See numstat.Stats.Percentiles for p50, p99, etc
+	QueueWait numstat.Stats
+	RunTime   numstat.Stats
+
+	// QueueWaitHistogram and RunTimeHistogram hold the same latencies as QueueWait and RunTime, but for all jobs
+	// since the executor was created
+	QueueWaitHistogram Histogram
+	RunTimeHistogram   Histogram
+}
+
+// Histogram is a cumulative latency histogram, in seconds
+type Histogram struct {
+	Buckets []HistogramBucket
+	Count   int64
+	Sum     float64
+}
+
+// HistogramBucket counts the latencies lower than or equal to UpperBound
+type HistogramBucket struct {
+	UpperBound float64
+	Count      int64
+}
+
+// Stats returns a snapshot of the executor statistics. Counters are read atomically together, so that for instance
+// Done + Pending always adds up to Submitted
+func (e *Executor[T]) Stats() Stats {
+	e.statsLock.Lock()
+	stats := Stats{
+		MaxParallelism: e.MaxParallelism(),
+		Submitted:      e.submitted.Load(),
+		Launched:       e.launched.Load(),
+		InFlight:       e.inFlight.Load(),
+		Done:           e.done.Load(),
+		Pending:        e.pending.Load(),
+		Failed:         e.failed.Load(),
+		Attempts:       e.attempts.Load(),
+		Retries:        e.retries.Load(),
+		TimedOut:       e.timedOut.Load(),
+		Cancelled:      e.cancelled.Load(),
+		Deduplicated:   e.deduplicated.Load(),
+		Throttled:      e.throttled.Load(),
+		ThrottledTime:  time.Duration(e.throttledTime.Load()),
+		PeakInFlight:   e.peakInFlight.Load(),
+	}
+	e.statsLock.Unlock()
+
+	stats.Uptime = e.clock.Now().Sub(e.created)
+	if seconds := stats.Uptime.Seconds(); seconds > 0 {
+		stats.Throughput = float64(stats.Done) / seconds
+	}
+	queueWaits, queueWaitHistogram := e.queueWaits.snapshot()
+	runTimes, runTimeHistogram := e.runTimes.snapshot()
+	stats.QueueWait = numstat.CalcStatsUnsorted(queueWaits, 0)
+	stats.RunTime = numstat.CalcStatsUnsorted(runTimes, 0)
+	stats.QueueWaitHistogram = queueWaitHistogram
+	stats.RunTimeHistogram = runTimeHistogram
+	return stats
+}
+
+// samples keeps the latest statsWindow durations, in seconds, along with a histogram of all durations
+type samples struct {
+	lock    sync.Mutex
+	values  []float64
+	next    int
+	buckets [len(latencyBuckets)]int64 // not cumulative, unlike HistogramBucket
+	count   int64
+	sum     float64
+}
+
+func (s *samples) add(d time.Duration) {
+	seconds := d.Seconds()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.count++
+	s.sum += seconds
+	for i, upperBound := range latencyBuckets {
+		if seconds <= upperBound {
+			s.buckets[i]++
+			break
+		}
+	}
+	if len(s.values) < statsWindow {
+		s.values = append(s.values, seconds)
+		return
+	}
+	s.values[s.next] = seconds
+	s.next = (s.next + 1) % statsWindow
+}
+
+func (s *samples) snapshot() ([]float64, Histogram) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	values := make([]float64, len(s.values))
+	copy(values, s.values)
+	histogram := Histogram{
+		Buckets: make([]HistogramBucket, len(latencyBuckets)),
+		Count:   s.count,
+		Sum:     s.sum,
+	}
+	var cumulative int64
+	for i, upperBound := range latencyBuckets {
+		cumulative += s.buckets[i]
+		histogram.Buckets[i] = HistogramBucket{UpperBound: upperBound, Count: cumulative}
+	}
+	return values, histogram
+}
@@ -0,0 +1,192 @@
+package executor
+
+import (
+	"context"
+	"iter"
+
+	"github.com/bcap/go-lib/result"
+)
+
+// defaultReadAhead is how many inputs are read ahead by the streaming collectors when the executor has no
+// parallelism limit
+const defaultReadAhead = 64
+
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	readAhead int
+	unordered bool
+}
+
+// WithReadAhead sets how many inputs can be read from the source and not yet be yielded back as results, which
+// bounds the memory used when streaming large or unbounded inputs. Defaults to the executor maxParallelism, or 64
+// when the executor has no parallelism limit
+func WithReadAhead(readAhead int) StreamOption {
+	return func(o *streamOptions) {
+		o.readAhead = readAhead
+	}
+}
+
+// Unordered makes the streaming collectors yield results as soon as they are ready instead of in the order of the
+// inputs, so a slow job does not hold back the results of the ones after it
+func Unordered() StreamOption {
+	return func(o *streamOptions) {
+		o.unordered = true
+	}
+}
+
+// CollectSeq applies a function to all elements of the given sequence, yielding each input along with its result as
+// they become available. Unlike Collect, inputs are read as the results are consumed, so it also works with
+// unbounded inputs. Example:
+//
+//	urls := maps.Keys(pages) // any iter.Seq[string]
+//	for url, r := range CollectSeq(ctx, 4, urls, fetch) {
+//		fmt.Println(url, r.Value, r.Err)
+//	}
+//
+// Results are yielded in the order of the inputs unless the Unordered option is given. Stopping the iteration early
+// stops reading inputs and cancels the context of jobs that were not yielded yet
+func CollectSeq[In, T any](ctx context.Context, maxParallelism int, seq iter.Seq[In], fn func(context.Context, In) (T, error), opts ...StreamOption) iter.Seq2[In, *result.Result[T]] {
+	return CollectSeqE(ctx, New[T](maxParallelism), seq, fn, opts...)
+}
+
+// CollectSeqE is the same as CollectSeq, but you can pass an existing Executor
+func CollectSeqE[In, T any](ctx context.Context, e *Executor[T], seq iter.Seq[In], fn func(context.Context, In) (T, error), opts ...StreamOption) iter.Seq2[In, *result.Result[T]] {
+	return collectStream(ctx, e, func(context.Context) iter.Seq[In] { return seq }, fn, opts...)
+}
+
+// CollectChan is the same as CollectSeq, but reads the inputs from a channel until it is closed or ctx is done
+func CollectChan[In, T any](ctx context.Context, maxParallelism int, ch <-chan In, fn func(context.Context, In) (T, error), opts ...StreamOption) iter.Seq2[In, *result.Result[T]] {
+	return CollectChanE(ctx, New[T](maxParallelism), ch, fn, opts...)
+}
+
+// CollectChanE is the same as CollectChan, but you can pass an existing Executor
+func CollectChanE[In, T any](ctx context.Context, e *Executor[T], ch <-chan In, fn func(context.Context, In) (T, error), opts ...StreamOption) iter.Seq2[In, *result.Result[T]] {
+	return collectStream(ctx, e, func(ctx context.Context) iter.Seq[In] { return chanSeq(ctx, ch) }, fn, opts...)
+}
+
+// chanSeq reads from ch until it is closed or ctx is done. Inputs are not taken from ch once ctx is done, so that
+// they are left for other readers
+func chanSeq[In any](ctx context.Context, ch <-chan In) iter.Seq[In] {
+	return func(yield func(In) bool) {
+		for ctx.Err() == nil {
+			select {
+			case input, ok := <-ch:
+				if !ok || !yield(input) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// collectStream implements the streaming collectors. The inputs sequence is built for each iteration with the
+// iteration context, which is cancelled as soon as the iteration stops
+func collectStream[In, T any](ctx context.Context, e *Executor[T], seq func(context.Context) iter.Seq[In], fn func(context.Context, In) (T, error), opts ...StreamOption) iter.Seq2[In, *result.Result[T]] {
+	options := streamOptions{readAhead: e.MaxParallelism()}
+	if options.readAhead <= 0 {
+		options.readAhead = defaultReadAhead
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.readAhead = max(options.readAhead, 1)
+
+	return func(yield func(In, *result.Result[T]) bool) {
+		// cancelled when the iteration stops, which stops the producer and the jobs not yielded yet
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		s := newStream[In](ctx, e, seq(ctx), fn, options)
+		if options.unordered {
+			s.yieldUnordered(ctx, yield)
+		} else {
+			s.yieldOrdered(ctx, yield)
+		}
+	}
+}
+
+type streamItem[In, T any] struct {
+	input  In
+	future *Future[T]
+}
+
+// stream reads inputs in a separate goroutine and submits them to the executor, keeping at most readAhead of them
+// not yet yielded
+type stream[In, T any] struct {
+	slots     chan struct{}          // one per input read and not yet yielded
+	submitted chan streamItem[In, T] // in input order, closed when there are no more inputs
+	completed chan streamItem[In, T] // in completion order, only used in unordered mode
+}
+
+func newStream[In, T any](ctx context.Context, e *Executor[T], seq iter.Seq[In], fn func(context.Context, In) (T, error), options streamOptions) *stream[In, T] {
+	s := &stream[In, T]{
+		slots:     make(chan struct{}, options.readAhead),
+		submitted: make(chan streamItem[In, T], options.readAhead),
+	}
+	if options.unordered {
+		s.completed = make(chan streamItem[In, T], options.readAhead)
+	}
+	go s.produce(ctx, e, seq, fn)
+	return s
+}
+
+func (s *stream[In, T]) produce(ctx context.Context, e *Executor[T], seq iter.Seq[In], fn func(context.Context, In) (T, error)) {
+	defer close(s.submitted)
+	next, stop := iter.Pull(seq)
+	defer stop()
+	for {
+		// wait for room before reading the next input
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		input, ok := next()
+		if !ok {
+			return
+		}
+		item := streamItem[In, T]{
+			input:  input,
+			future: e.SubmitCtx(ctx, func(ctx context.Context) (T, error) { return fn(ctx, input) }),
+		}
+		// channels have room for all items holding a slot, so these sends never block
+		s.submitted <- item
+		if s.completed != nil {
+			item.future.OnComplete(func(*result.Result[T]) { s.completed <- item })
+		}
+	}
+}
+
+func (s *stream[In, T]) yieldOrdered(ctx context.Context, yield func(In, *result.Result[T]) bool) {
+	for item := range s.submitted {
+		r := item.future.Get(ctx)
+		<-s.slots
+		if !yield(item.input, r) {
+			return
+		}
+	}
+}
+
+func (s *stream[In, T]) yieldUnordered(ctx context.Context, yield func(In, *result.Result[T]) bool) {
+	// a completion may be received before its submission, so pending can go temporarily negative
+	pending := 0
+	submitted := s.submitted
+	for submitted != nil || pending > 0 {
+		select {
+		case _, ok := <-submitted:
+			if !ok {
+				submitted = nil
+				continue
+			}
+			pending++
+		case item := <-s.completed:
+			pending--
+			<-s.slots
+			if !yield(item.input, item.future.Get(ctx)) {
+				return
+			}
+		}
+	}
+}
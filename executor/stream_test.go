@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectSeq(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	// later inputs finish first, but results are still yielded in input order
+	delay := func(_ context.Context, i int) (int, error) {
+		time.Sleep(time.Duration(5-i) * 5 * time.Millisecond)
+		if i == 3 {
+			return 0, errBoom
+		}
+		return i * 10, nil
+	}
+	inputs, values := []int{}, []int{}
+	for input, r := range CollectSeq(ctx, 5, slices.Values([]int{0, 1, 2, 3, 4}), delay) {
+		inputs = append(inputs, input)
+		if input == 3 {
+			require.ErrorIs(t, r.Err, errBoom)
+			continue
+		}
+		values = append(values, r.Must())
+	}
+	require.Equal(t, []int{0, 1, 2, 3, 4}, inputs)
+	require.Equal(t, []int{0, 10, 20, 40}, values)
+
+	// unordered mode yields results as they complete, so the first input being stuck does not hold back the others
+	gate := make(chan struct{})
+	stuck := func(_ context.Context, i int) (int, error) {
+		if i == 0 {
+			<-gate
+		}
+		return i, nil
+	}
+	inputs = []int{}
+	for input := range CollectSeq(ctx, 5, slices.Values([]int{0, 1, 2, 3, 4}), stuck, Unordered()) {
+		inputs = append(inputs, input)
+		if len(inputs) == 4 {
+			close(gate)
+		}
+	}
+	require.ElementsMatch(t, []int{1, 2, 3, 4}, inputs[:4])
+	require.Equal(t, 0, inputs[4])
+
+	require.Empty(t, slices.Collect(func(yield func(int) bool) {
+		for input := range CollectSeq(ctx, 1, slices.Values([]int{}), delay) {
+			yield(input)
+		}
+	}))
+}
+
+func TestCollectSeqReadAhead(t *testing.T) {
+	ctx := context.Background()
+	identity := func(_ context.Context, i int) (int, error) { return i, nil }
+
+	for _, opts := range [][]StreamOption{{WithReadAhead(3)}, {WithReadAhead(3), Unordered()}} {
+		// an unbounded input is only read as far as the read ahead allows
+		var read atomic.Int64
+		naturals := func(yield func(int) bool) {
+			for i := 0; ; i++ {
+				read.Add(1)
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		yielded := 0
+		for _, r := range CollectSeq(ctx, 2, naturals, identity, opts...) {
+			require.NoError(t, r.Err)
+			yielded++
+			require.LessOrEqual(t, read.Load(), int64(yielded+3))
+			if yielded == 100 {
+				break
+			}
+		}
+		require.Equal(t, 100, yielded)
+
+		// stopping the iteration stops reading the input, once the producer notices it
+		time.Sleep(10 * time.Millisecond)
+		stopped := read.Load()
+		require.LessOrEqual(t, stopped, int64(yielded+3))
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, stopped, read.Load())
+	}
+}
+
+func TestCollectChan(t *testing.T) {
+	ctx := context.Background()
+	double := func(_ context.Context, i int) (int, error) { return i * 2, nil }
+
+	ch := make(chan int)
+	go func() {
+		for i := range 10 {
+			ch <- i
+		}
+		close(ch)
+	}()
+	values := []int{}
+	for _, r := range CollectChan(ctx, 3, ch, double) {
+		values = append(values, r.Must())
+	}
+	require.Equal(t, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}, values)
+
+	// results are yielded while waiting for more inputs, and the iteration stops once ctx is done
+	ch = make(chan int)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	go func() { ch <- 1 }()
+	values = []int{}
+	for _, r := range CollectChan(timeoutCtx, 3, ch, double) {
+		values = append(values, r.Must())
+		require.NoError(t, timeoutCtx.Err())
+	}
+	require.Equal(t, []int{2}, values)
+
+	// stopping the iteration early stops reading from the channel, leaving the next inputs to other readers
+	ch = make(chan int)
+	go func() { ch <- 1 }()
+	for _, r := range CollectChan(ctx, 3, ch, double) {
+		require.Equal(t, 2, r.Must())
+		break
+	}
+	select {
+	case ch <- 2:
+		require.FailNow(t, "input read after the iteration stopped")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
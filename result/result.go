@@ -1,6 +1,11 @@
 package result
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
 
 // Result represents the return of a function call, which is normally a value provided by the user or an error
 // Inspired by Rust's Result type
@@ -171,11 +176,11 @@ func (rs ResultsMap[K, T]) Error() error {
 	if len(errorsMap) == 0 {
 		return nil
 	}
-	errors := make([]error, 0, len(errorsMap))
+	errs := make([]error, 0, len(errorsMap))
 	for _, err := range errorsMap {
-		errors = append(errors, err)
+		errs = append(errs, err)
 	}
-	return &ResultsError{Errors: errors}
+	return newResultsError(errs)
 }
 
 // Error
@@ -183,6 +188,15 @@ type ResultsError struct {
 	Errors []error
 }
 
+// newResultsError builds a ResultsError with errors caused by context cancellation placed last, so that when one
+// failure cancels the others the root cause is reported first
+func newResultsError(errs []error) *ResultsError {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return !errors.Is(errs[i], context.Canceled) && errors.Is(errs[j], context.Canceled)
+	})
+	return &ResultsError{Errors: errs}
+}
+
 func (e ResultsError) Error() string {
 	if len(e.Errors) == 0 {
 		return ""
@@ -194,9 +208,9 @@ func (e ResultsError) Error() string {
 }
 
 func (rs Results[T]) Error() error {
-	errors := rs.ErrorsOnly()
-	if len(errors) == 0 {
+	errs := rs.ErrorsOnly()
+	if len(errs) == 0 {
 		return nil
 	}
-	return &ResultsError{Errors: errors}
+	return newResultsError(errs)
 }
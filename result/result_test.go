@@ -1,7 +1,9 @@
 package result
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -47,3 +49,29 @@ func TestResults(t *testing.T) {
 	require.Equal(t, 3, ok)
 	require.Equal(t, 1, nok)
 }
+
+func TestResultsErrorRootCauseFirst(t *testing.T) {
+	rootCause := errors.New("root cause")
+	results := Results[int]{
+		NewResult(0, context.Canceled),
+		NewResult(1, nil),
+		NewResult(0, fmt.Errorf("aborted: %w", context.Canceled)),
+		NewResult(0, rootCause),
+	}
+
+	var resultsErr *ResultsError
+	require.ErrorAs(t, results.Error(), &resultsErr)
+	require.Len(t, resultsErr.Errors, 3)
+	require.Equal(t, rootCause, resultsErr.Errors[0])
+	require.ErrorIs(t, resultsErr.Errors[1], context.Canceled)
+	require.ErrorIs(t, resultsErr.Errors[2], context.Canceled)
+
+	resultsMap := ResultsMap[string, int]{
+		"a": NewResult(0, context.Canceled),
+		"b": NewResult(0, rootCause),
+		"c": NewResult(0, context.Canceled),
+	}
+	require.ErrorAs(t, resultsMap.Error(), &resultsErr)
+	require.Len(t, resultsErr.Errors, 3)
+	require.Equal(t, rootCause, resultsErr.Errors[0])
+}